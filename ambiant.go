@@ -1,195 +1,271 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/faiface/beep"
-	"github.com/faiface/beep/effects"
-	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
-	"github.com/getlantern/systray"
+	"rogverse.fyi/ambiantgo/internal/audio"
+	"rogverse.fyi/ambiantgo/internal/config"
+	"rogverse.fyi/ambiantgo/internal/logging"
+	"rogverse.fyi/ambiantgo/internal/remote"
+	"rogverse.fyi/ambiantgo/internal/ui"
 )
 
-type SoundPlayer struct {
-	sounds          []string
-	currentSound    string
-	currentStreamer beep.StreamSeekCloser
-	format          beep.Format
-	isPlaying       bool
-	volume          float64
-}
+// logger is the log of the app itself, as opposed to its packages
+var logger = logging.For("main")
+
+func main() {
+	// Portable mode moves the config file, so it is known before the flags
+	config.Portable = portableMode(os.Args[1:])
+	logging.Setup(config.AppDataDir())
 
-func (sp *SoundPlayer) loadSound(filename string) error {
-	// Close existing streamer if open
-	if sp.currentStreamer != nil {
-		sp.currentStreamer.Close()
+	// Settings from the config file are the defaults for the flags
+	cfg := config.Load(filepath.Join(config.AppDataDir(), "config.yaml"))
+
+	// A sounds folder from the config is relative to the install, one
+	// from the command line to the current folder
+	soundsDir := flag.String("sounds", config.ResourcePath(cfg.SoundsDir), "folder to load sounds from")
+	flag.Bool("portable", false, "keep the config, state and caches beside the executable")
+	headless := flag.Bool("headless", false, "run without a tray icon, controlled only by the command line and control API")
+	mediaKeys := flag.Bool("media-keys", false, "use the keyboard media keys to play, pause and switch sounds")
+	apiAddr := flag.String("api", "", "serve the control API on this address, e.g. 127.0.0.1:8091")
+	relayAddr := flag.String("relay", "", "serve the live mix to browsers on this address, e.g. :8090")
+	importDir := flag.String("import", "", "folder to watch for new sounds to move into the library")
+	importCategory := flag.String("import-category", "", "category to file imported sounds under, e.g. Nature/Rain")
+	dayVolume := flag.Float64("day-volume", 0, "baseline volume during the day, added to the selected volume")
+	nightVolume := flag.Float64("night-volume", -2, "baseline volume at night, added to the selected volume")
+	nightWindow := flag.String("night", "21:00-07:00", "time window the night volume applies to")
+	loudVolume := flag.Float64("loud-volume", -1, "volume at or above which listening counts as loud")
+	loudLimit := flag.Duration("loud-limit", 2*time.Hour, "continuous loud listening before a hearing reminder, 0 to disable")
+	loudReduce := flag.Bool("loud-reduce", false, "lower the volume when the hearing reminder is shown")
+	cacheSize := flag.Int64("pcm-cache-mb", 2048, "disk space for decoded sounds that start instantly, 0 to disable")
+	followDevice := flag.Bool("follow-device", true, "move playback to the new default output device when it changes")
+	pauseOnDisconnect := flag.Bool("pause-on-disconnect", false, "pause when the output device is disconnected, e.g. headphones unplugged")
+	headphonesOnly := flag.Bool("headphones-only", false, "only play while headphones are the active output")
+	pauseOnLock := flag.Bool("pause-on-lock", false, "pause while the session is locked or the system sleeps")
+	resumeOnUnlock := flag.Bool("resume-on-unlock", false, "with -pause-on-lock, play again after unlocking")
+	logLevel := flag.String("log-level", "info", "least severe messages to log: debug, info, warn or error")
+	logStderr := flag.Bool("log-stderr", true, "copy the log to stderr as well as the log file")
+	buffer := flag.Duration("buffer", cfg.Buffer, "audio buffered ahead of the output device; raise it if playback crackles, lower it for less latency")
+	preload := flag.Int64("preload-mb", cfg.PreloadMB, "memory a sound may decode to and still be held in memory instead of streamed from disk, 0 to stream every sound")
+	fade := flag.Duration("fade", time.Second, "how long playback fades in on play and out on pause")
+	sleepCustom := flag.Duration("sleep-custom", 2*time.Hour, "extra sleep timer length offered in the tray")
+	loopCrossfade := flag.Duration("loop-crossfade", time.Second, "how long the end of a sound fades into its start when it loops, 0 to disable")
+	trimSilence := flag.Bool("trim-silence", true, "skip the silence at the start and end of sound files so they loop cleanly")
+	normalize := flag.Bool("normalize", true, "bring every sound file to the same loudness")
+	loudnessTarget := flag.Float64("loudness-target", audio.LoudnessTarget, "loudness in LUFS that -normalize brings sound files to")
+	resumeMin := flag.Duration("resume-min", 5*time.Minute, "sounds at least this long resume where they stopped, shorter loops restart from the beginning")
+	switchFade := flag.Duration("switch-crossfade", 2*time.Second, "how long sounds fade in and out when the mix changes while playing, 0 to switch instantly")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), remote.CLIUsage)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nflags:")
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	// Open new sound file
-	f, err := os.Open(filename)
+	logging.SetStderr(*logStderr)
+	level, err := logging.ParseLevel(*logLevel)
 	if err != nil {
-		return err
+		logger.Error("Invalid -log-level", "err", err)
+		os.Exit(2)
+	}
+	logging.SetLevel(level)
+
+	// With a command, control the running instance instead of starting
+	// one. Anything else given is a sound file or folder to open.
+	var openPath string
+	if flag.NArg() > 0 {
+		_, err := os.Stat(flag.Arg(0))
+		if remote.IsCommand(flag.Arg(0)) || flag.NArg() > 1 || err != nil {
+			os.Exit(remote.RunCLI(flag.Args()))
+		}
+		openPath, _ = filepath.Abs(flag.Arg(0))
 	}
 
-	streamer, format, err := mp3.Decode(f)
+	// Only one instance runs at a time; launching another one hands the
+	// file to open, if any, to the running one
+	ipc, err := remote.ListenIPC()
+	if errors.Is(err, remote.ErrRunning) {
+		os.Exit(remote.Activate(openPath))
+	}
 	if err != nil {
-		return err
+		logger.Error("Opening the control socket failed", "err", err)
 	}
 
-	sp.currentStreamer = streamer
-	sp.format = format
-	sp.currentSound = filename
+	profile := audio.VolumeProfile{Day: *dayVolume, Night: *nightVolume}
+	profile.NightStart, profile.NightEnd, err = audio.ParseTimeWindow(*nightWindow)
+	if err != nil {
+		logger.Error("Invalid -night window", "err", err)
+		os.Exit(2)
+	}
 
-	return nil
-}
+	schedule := audio.ParseSchedule(cfg.Schedule)
+	audio.TonePresets = append(audio.TonePresets, cfg.Tones...)
+	audio.Streams = append(audio.Streams, cfg.Streams...)
+	audio.StarterSounds, _ = fs.Sub(starterSounds, "sounds")
 
-func (sp *SoundPlayer) play() error {
-	if sp.currentStreamer == nil {
-		return fmt.Errorf("no sound loaded")
-	}
+	soundPlayer := audio.NewPlayer(*soundsDir, cfg.OutputDevice)
+	soundPlayer.SetBaseline(profile.Baseline(time.Now()))
+	soundPlayer.Crossfade = *loopCrossfade
+	soundPlayer.SwitchFade = *switchFade
+	soundPlayer.Fade = *fade
+	soundPlayer.Buffer = *buffer
+	soundPlayer.Preload = *preload << 20
+	soundPlayer.ResumeMin = *resumeMin
 
-	// Initialize speaker if not already initialized
-	if err := speaker.Init(sp.format.SampleRate, sp.format.SampleRate.N(time.Second/10)); err != nil {
-		return err
-	}
+	// Show the app by name in the OS volume mixer
+	audio.RegisterAudioSession("AmbiantGo", sessionIcon())
 
-	// Reset streamer to beginning
-	sp.currentStreamer.Seek(0)
+	if *cacheSize > 0 {
+		soundPlayer.Cache = audio.NewPCMCache(filepath.Join(config.AppDataDir(), "pcm"), *cacheSize<<20)
+	}
 
-	// Create a looping streamer
-	loopStreamer := beep.Loop(-1, sp.currentStreamer)
+	soundPlayer.TrimSilence = *trimSilence
+	soundPlayer.Normalize = *normalize
+	audio.LoudnessTarget = *loudnessTarget
+	if *trimSilence || *normalize {
+		soundPlayer.Analysis = audio.LoadAnalysisCache(filepath.Join(config.AppDataDir(), "analysis.json"))
+	}
 
-	// Create a volume-controlled streamer
-	volumeCtrl := &beep.Ctrl{Streamer: loopStreamer, Paused: false}
+	if *relayAddr != "" {
+		soundPlayer.Relay = audio.NewAudioRelay()
+		audio.StartRelay(*relayAddr, soundPlayer.Relay)
+	}
 
-	volume := &effects.Volume{
-		Streamer: loopStreamer,
-		Base:     2,
-		Volume:   0,
-		Silent:   false,
+	// Notices go to the log when there is no desktop to show them on
+	notify := ui.ShowNotice
+	if *headless {
+		notify = func(title, message string) {
+			logger.Info(message, "title", title)
+		}
 	}
 
-	volume.Volume = sp.volume
+	rc := remote.New()
+	if ipc != nil {
+		remote.ServeIPC(ipc, rc)
+	}
+	updateMediaSession := ui.StartMediaSession(rc)
+	if *apiAddr != "" {
+		if cfg.APIToken == "" {
+			cfg.APIToken = remote.NewToken()
+			cfg.Save()
+		}
+		rc.Token = cfg.APIToken
+		if err := remote.Serve(*apiAddr, rc); err != nil {
+			logger.Error("Invalid -api address", "err", err)
+			os.Exit(2)
+		}
+	}
 
-	speaker.Play(volume)
-	sp.isPlaying = true
-	return volumeCtrl.Streamer.Err()
-}
+	guard := &audio.ListeningGuard{
+		Threshold: *loudVolume,
+		Limit:     *loudLimit,
+		Reduce:    *loudReduce,
+	}
 
-func (sp *SoundPlayer) pause() {
-	speaker.Clear()
-	sp.isPlaying = false
-}
+	output := &audio.OutputPolicy{
+		Follow:            *followDevice,
+		PauseOnDisconnect: *pauseOnDisconnect,
+		HeadphonesOnly:    *headphonesOnly,
+	}
+	outputID, headphones := audio.DefaultOutput()
+	output.OnHeadphones = headphones
 
-func (sp *SoundPlayer) setVolume(vol float64) {
-	sp.volume = vol
-	if sp.isPlaying {
+	// Each output device keeps its own volume
+	volumes := audio.LoadDeviceVolumes(filepath.Join(config.AppDataDir(), "device-volumes.json"), outputID)
+	// Resume the mix that was playing when the app last quit
+	statePath := filepath.Join(config.AppDataDir(), "state.json")
+	state, restored := audio.LoadState(statePath)
+	if restored {
+		soundPlayer.RestoreState(state)
+	}
 
-		// Replay with new volume
-		sp.pause()
-		sp.play()
+	startVolume, ok := volumes.Lookup()
+	if !ok && restored {
+		startVolume, ok = state.Volume, true
 	}
-}
+	if !ok {
+		startVolume = cfg.Volume
+	}
+	soundPlayer.SetVolume(startVolume)
 
-func main() {
-	soundPlayer := &SoundPlayer{
-		sounds: getSounds(),
-		volume: 0,
-	}
-
-	// Try to load first sound by default
-	if len(soundPlayer.sounds) > 0 {
-		soundPlayer.loadSound(soundPlayer.sounds[0])
-		soundPlayer.setVolume(-2)
-		soundPlayer.play()
-	}
-
-	systray.Run(func() {
-		// Set the icon from ICO file
-		systray.SetIcon(loadIcon("ambiantgo.ico"))
-
-		// Create menu items
-		mPlay := systray.AddMenuItem("Play", "Play sound")
-		mPause := systray.AddMenuItem("Pause", "Pause sound")
-
-		// Volume submenu
-		mVolume := systray.AddMenuItem("Volume", "Adjust Volume")
-		mVolumeLow := mVolume.AddSubMenuItem("Low", "Set low volume")
-		mVolumeMedium := mVolume.AddSubMenuItem("Medium", "Set medium volume")
-		mVolumeHigh := mVolume.AddSubMenuItem("High", "Set high volume")
-
-		// Sounds submenu
-		mSounds := systray.AddMenuItem("Sounds", "Select Sound")
-		soundClicked := make(chan string)
-		for _, sound := range soundPlayer.sounds {
-			item := mSounds.AddSubMenuItem(filepath.Base(sound), "Select this sound")
-			go func(p string, m *systray.MenuItem) {
-				for {
-					<-m.ClickedCh
-					soundClicked <- p
-				}
-			}(sound, item)
+	if len(soundPlayer.Channels) > 0 {
+		if state.Playing && cfg.Autoplay && output.CanPlay() {
+			soundPlayer.Play()
 		}
-
-		mQuit := systray.AddMenuItem("Quit", "Quit the app")
-
-		go func() {
-			for {
-				select {
-				case <-mPlay.ClickedCh:
-					soundPlayer.play()
-				case <-mPause.ClickedCh:
-					soundPlayer.pause()
-				case <-mVolumeLow.ClickedCh:
-					soundPlayer.setVolume(-5)
-				case <-mVolumeMedium.ClickedCh:
-					soundPlayer.setVolume(-1)
-				case <-mVolumeHigh.ClickedCh:
-					soundPlayer.setVolume(0)
-				case <-mQuit.ClickedCh:
-					systray.Quit()
-					return
-				case path := <-soundClicked:
-					err := soundPlayer.loadSound(path)
-					if err != nil {
-						log.Println("Error loading sound:", err)
-					}
-					// If currently playing, restart with new sound
-					if soundPlayer.isPlaying {
-						soundPlayer.play()
-					}
-				}
+	} else if len(soundPlayer.Sounds) > 0 {
+		// Load the last picked sound, or the first one if it's gone
+		startSound := soundPlayer.Sounds[0]
+		for _, sound := range soundPlayer.Sounds {
+			if sound == cfg.LastSound {
+				startSound = sound
 			}
-		}()
-	}, func() {
-		// Cleanup
-		if soundPlayer.currentStreamer != nil {
-			soundPlayer.currentStreamer.Close()
 		}
-		speaker.Close()
-	})
-}
+		soundPlayer.SelectSound(startSound)
+		if cfg.Autoplay && output.CanPlay() {
+			soundPlayer.Play()
+		}
+	}
 
-// loadIcon reads an ICO file and returns its byte content
-func loadIcon(filename string) []byte {
-	// Read the entire ICO file
-	iconBytes, err := os.ReadFile(filename)
-	if err != nil {
-		log.Printf("Error loading icon: %v", err)
-		return nil
+	// A sound opened from the command line or the file manager plays at once
+	if openPath != "" {
+		soundPlayer.SelectSound(openPath)
+		if soundPlayer.Channel(openPath) != nil && !soundPlayer.IsPlaying() && output.CanPlay() {
+			soundPlayer.Play()
+		}
+	}
+
+	if *importDir != "" {
+		audio.WatchImportFolder(*importDir, soundPlayer.SoundsDir, *importCategory, soundPlayer.Analysis, 5*time.Second)
+	}
+
+	// Rotate sounds mode, kept on across restarts
+	rotate := audio.NewRotation(cfg.RotateInterval)
+	rotate.Set(cfg.Rotate)
+
+	a := &ui.App{
+		Player:             soundPlayer,
+		Config:             cfg,
+		Remote:             rc,
+		Profile:            profile,
+		Guard:              guard,
+		Output:             output,
+		Volumes:            volumes,
+		Rotate:             rotate,
+		Away:               &ui.AwayPolicy{Pause: *pauseOnLock, Resume: *resumeOnUnlock},
+		Schedule:           schedule,
+		StatePath:          statePath,
+		SleepCustom:        *sleepCustom,
+		MediaKeys:          *mediaKeys,
+		Icons:              icons,
+		Notify:             notify,
+		UpdateMediaSession: updateMediaSession,
 	}
-	return iconBytes
+	a.Watch()
+
+	if *headless {
+		a.RunHeadless()
+		return
+	}
+	a.RunTray()
 }
 
-func getSounds() []string {
-	matches, err := filepath.Glob("./sounds/*.mp3")
-	if err != nil {
-		log.Printf("Error finding sounds: %v", err)
-		return []string{}
+// portableMode reports whether -portable is among the arguments, before
+// they are parsed
+func portableMode(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		switch arg {
+		case "-portable", "--portable", "-portable=true", "--portable=true":
+			return true
+		}
 	}
-	return matches
+	return false
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"rogverse.fyi/ambiantgo/internal/config"
+)
+
+// The starter sounds and the icons are built into the binary, so the app
+// works from any folder
+var (
+	//go:embed sounds/*.mp3
+	starterSounds embed.FS
+
+	//go:embed ambiantgo.ico icons/*.ico
+	icons embed.FS
+)
+
+// sessionIcon writes the app icon to the app data folder for the Windows
+// volume mixer, which only takes a file, and returns its path. It returns
+// "" elsewhere or if the icon can't be written.
+func sessionIcon() string {
+	if runtime.GOOS != "windows" {
+		return ""
+	}
+	data, err := icons.ReadFile("ambiantgo.ico")
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(config.AppDataDir(), "ambiantgo.ico")
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return path
+	}
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return ""
+	}
+	return path
+}
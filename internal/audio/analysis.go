@@ -0,0 +1,167 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// silenceThreshold is the level below which a sample counts as silence,
+// about -60 dBFS
+const silenceThreshold = 0.001
+
+// silenceMargin is kept before the first sound and after the last one so
+// the attack and the decay aren't cut
+const silenceMargin = 10 * time.Millisecond
+
+// AnalysisCache remembers what decoding a whole sound file found out: how
+// much silence it starts and ends with, and how loud it is. A file is only
+// analysed once, in the background the first time it plays.
+type AnalysisCache struct {
+	path string
+	done chan string // sounds whose analysis has just finished
+
+	mu      sync.Mutex
+	entries map[string]soundAnalysis
+	pending map[string]bool // files being analysed
+}
+
+// analysisVersion changes when decoding does, e.g. WAV files playing at
+// full level, so older results are measured again
+const analysisVersion = 2
+
+// soundAnalysis is what was found in one version of a file
+type soundAnalysis struct {
+	Version  int       `json:"version"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Start    float64   `json:"start"`    // seconds of silence at the start
+	End      float64   `json:"end"`      // seconds of silence at the end
+	Loudness float64   `json:"loudness"` // integrated loudness in LUFS
+	Peak     float64   `json:"peak"`     // highest sample level
+}
+
+// LoadAnalysisCache reads the analysis results kept at path
+func LoadAnalysisCache(path string) *AnalysisCache {
+	c := &AnalysisCache{
+		path:    path,
+		done:    make(chan string, 16),
+		entries: make(map[string]soundAnalysis),
+		pending: make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Reading the sound analysis failed", "err", err)
+		}
+		return c
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		logger.Error("Parsing the sound analysis failed", "err", err)
+	}
+	return c
+}
+
+// lookup returns the analysis of a sound file or starter sound, starting
+// it in the background the first time
+func (c *AnalysisCache) lookup(filename string) (soundAnalysis, bool) {
+	info, err := statSoundFile(filename)
+	if err != nil {
+		return soundAnalysis{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, found := c.entries[filename]; found && e.Version == analysisVersion &&
+		e.Size == info.Size() && e.ModTime.Equal(info.ModTime()) {
+		return e, true
+	}
+	if !c.pending[filename] {
+		c.pending[filename] = true
+		go c.analyse(filename, info)
+	}
+	return soundAnalysis{}, false
+}
+
+// analyse decodes a sound and records what it found
+func (c *AnalysisCache) analyse(filename string, info fs.FileInfo) {
+	a, err := analyseSound(filename)
+
+	c.mu.Lock()
+	delete(c.pending, filename)
+	if err == nil {
+		a.Version, a.Size, a.ModTime = analysisVersion, info.Size(), info.ModTime()
+		c.entries[filename] = a
+	}
+	data, marshalErr := json.MarshalIndent(c.entries, "", "  ")
+	c.mu.Unlock()
+
+	if err != nil {
+		logger.Error("Analysing a sound failed", "sound", filename, "err", err)
+		return
+	}
+	if marshalErr == nil {
+		os.MkdirAll(filepath.Dir(c.path), 0o755)
+		marshalErr = os.WriteFile(c.path, data, 0o644)
+	}
+	if marshalErr != nil {
+		logger.Error("Saving the sound analysis failed", "err", marshalErr)
+	}
+
+	select {
+	case c.done <- filename:
+	default:
+	}
+}
+
+// analyseSound decodes a sound once, finding the seconds of silence it
+// opens and closes with, less the margin, and measuring its loudness. A
+// silent file has nothing to trim.
+func analyseSound(filename string) (soundAnalysis, error) {
+	streamer, format, err := openAudio(filename)
+	if err != nil {
+		return soundAnalysis{}, err
+	}
+	defer streamer.Close()
+
+	meter := newLoudnessMeter(format.SampleRate)
+	first, last, n := -1, -1, 0
+	samples := make([][2]float64, 4096)
+	for {
+		read, ok := streamer.Stream(samples)
+		meter.add(samples[:read])
+		for i, s := range samples[:read] {
+			if math.Abs(s[0]) > silenceThreshold || math.Abs(s[1]) > silenceThreshold {
+				if first < 0 {
+					first = n + i
+				}
+				last = n + i
+			}
+		}
+		n += read
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		return soundAnalysis{}, err
+	}
+
+	a := soundAnalysis{Peak: meter.peak, Loudness: meter.loudness()}
+	if math.IsInf(a.Loudness, 0) {
+		// JSON has no infinity; a sound this quiet is left as it is
+		a.Loudness = 0
+	}
+	if first >= 0 {
+		margin := format.SampleRate.N(silenceMargin)
+		a.Start = format.SampleRate.D(max(first-margin, 0)).Seconds()
+		a.End = format.SampleRate.D(max(n-1-last-margin, 0)).Seconds()
+	}
+	return a, nil
+}
@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// biquad is a second order IIR filter, keeping the state of both stereo
+// channels
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     [2]float64
+}
+
+// process filters one sample of channel ch
+func (f *biquad) process(x float64, ch int) float64 {
+	y := f.b0*x + f.b1*f.x1[ch] + f.b2*f.x2[ch] - f.a1*f.y1[ch] - f.a2*f.y2[ch]
+	f.x2[ch], f.x1[ch] = f.x1[ch], x
+	f.y2[ch], f.y1[ch] = f.y1[ch], y
+	return y
+}
+
+// normalize divides the coefficients by a0
+func (f *biquad) normalize(a0 float64) *biquad {
+	f.b0 /= a0
+	f.b1 /= a0
+	f.b2 /= a0
+	f.a1 /= a0
+	f.a2 /= a0
+	return f
+}
+
+// pass records x as both input and output of channel ch, as a filter at
+// 0 dB would have, without filtering it
+func (f *biquad) pass(x float64, ch int) {
+	f.x2[ch], f.x1[ch] = f.x1[ch], x
+	f.y2[ch], f.y1[ch] = f.y1[ch], x
+}
+
+// retune takes over the coefficients of g, keeping the state so a running
+// filter changes without a click
+func (f *biquad) retune(g *biquad) {
+	f.b0, f.b1, f.b2, f.a1, f.a2 = g.b0, g.b1, g.b2, g.a1, g.a2
+}
+
+// The filters below follow Robert Bristow-Johnson's audio EQ cookbook;
+// freq is in Hz and gain in dB.
+
+func lowShelf(sampleRate beep.SampleRate, freq, gain float64) *biquad {
+	a, cos, alpha := cookbook(sampleRate, freq, gain, 1/math.Sqrt2)
+	sq := 2 * math.Sqrt(a) * alpha
+	return (&biquad{
+		b0: a * ((a + 1) - (a-1)*cos + sq),
+		b1: 2 * a * ((a - 1) - (a+1)*cos),
+		b2: a * ((a + 1) - (a-1)*cos - sq),
+		a1: -2 * ((a - 1) + (a+1)*cos),
+		a2: (a + 1) + (a-1)*cos - sq,
+	}).normalize((a + 1) + (a-1)*cos + sq)
+}
+
+func highShelf(sampleRate beep.SampleRate, freq, gain float64) *biquad {
+	a, cos, alpha := cookbook(sampleRate, freq, gain, 1/math.Sqrt2)
+	sq := 2 * math.Sqrt(a) * alpha
+	return (&biquad{
+		b0: a * ((a + 1) + (a-1)*cos + sq),
+		b1: -2 * a * ((a - 1) + (a+1)*cos),
+		b2: a * ((a + 1) + (a-1)*cos - sq),
+		a1: 2 * ((a - 1) - (a+1)*cos),
+		a2: (a + 1) - (a-1)*cos - sq,
+	}).normalize((a + 1) - (a-1)*cos + sq)
+}
+
+func peaking(sampleRate beep.SampleRate, freq, gain, q float64) *biquad {
+	a, cos, alpha := cookbook(sampleRate, freq, gain, q)
+	return (&biquad{
+		b0: 1 + alpha*a,
+		b1: -2 * cos,
+		b2: 1 - alpha*a,
+		a1: -2 * cos,
+		a2: 1 - alpha/a,
+	}).normalize(1 + alpha/a)
+}
+
+func lowPass(sampleRate beep.SampleRate, freq, q float64) *biquad {
+	_, cos, alpha := cookbook(sampleRate, freq, 0, q)
+	return (&biquad{
+		b0: (1 - cos) / 2,
+		b1: 1 - cos,
+		b2: (1 - cos) / 2,
+		a1: -2 * cos,
+		a2: 1 - alpha,
+	}).normalize(1 + alpha)
+}
+
+// cookbook returns the cookbook's A, cos(w0) and alpha, keeping freq
+// below the Nyquist frequency
+func cookbook(sampleRate beep.SampleRate, freq, gain, q float64) (a, cos, alpha float64) {
+	rate := float64(sampleRate)
+	w := 2 * math.Pi * math.Min(freq, rate*0.45) / rate
+	return math.Pow(10, gain/40), math.Cos(w), math.Sin(w) / (2 * q)
+}
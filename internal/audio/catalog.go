@@ -0,0 +1,163 @@
+package audio
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Catalog is the list of sound packs offered for download, published as
+// JSON by whoever curates it
+type Catalog struct {
+	Packs []CatalogPack `json:"packs"`
+}
+
+// CatalogPack is a set of sounds downloaded together
+type CatalogPack struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Sounds      []CatalogSound `json:"sounds"`
+}
+
+// CatalogSound is one file of a pack, checked against its SHA-256 before
+// it is moved into the sounds folder
+type CatalogSound struct {
+	File   string `json:"file"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// catalogClient fetches the catalog; sound downloads may take longer
+var catalogClient = &http.Client{Timeout: 30 * time.Second}
+
+// FetchCatalog downloads and parses the catalog at url
+func FetchCatalog(url string) (*Catalog, error) {
+	resp, err := catalogClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("catalog server answered %s", resp.Status)
+	}
+
+	var c Catalog
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&c); err != nil {
+		return nil, fmt.Errorf("parsing catalog: %w", err)
+	}
+	return &c, nil
+}
+
+// Size returns the total size of the pack's files in bytes
+func (p CatalogPack) Size() int64 {
+	var total int64
+	for _, s := range p.Sounds {
+		total += s.Size
+	}
+	return total
+}
+
+// Installed reports whether every file of the pack is in dir
+func (p CatalogPack) Installed(dir string) bool {
+	for _, s := range p.Sounds {
+		if _, err := os.Stat(filepath.Join(dir, filepath.Base(s.File))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Download fetches the pack's sounds that aren't in dir yet. Each file is
+// written next to its final name and only renamed once its checksum
+// matches, so the library never lists a partial download. progress is
+// called with the bytes done whenever another percent of the pack is in.
+func (p CatalogPack) Download(dir string, progress func(done, total int64)) error {
+	total := p.Size()
+	var done int64
+	percent := int64(-1)
+	report := func(n int64) {
+		done += n
+		if total > 0 && done*100/total != percent {
+			percent = done * 100 / total
+			progress(done, total)
+		}
+	}
+
+	for _, s := range p.Sounds {
+		name := filepath.Base(s.File)
+		if !isSupportedSound(name) || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("invalid file name %q in pack %s", s.File, p.Name)
+		}
+		dest := filepath.Join(dir, name)
+		if _, err := os.Stat(dest); err == nil {
+			report(s.Size)
+			continue
+		}
+		if err := downloadSound(s, dest, report); err != nil {
+			return fmt.Errorf("downloading %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// downloadSound fetches one sound into dest, checking its checksum. A
+// download may take as long as it needs, but one that stops sending is
+// given up like a stalled stream.
+func downloadSound(s CatalogSound, dest string, report func(n int64)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+
+	stall := time.AfterFunc(streamStall, cancel)
+	defer stall.Stop()
+
+	part := dest + ".part"
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hash, progressWriter(report)), &stallReader{resp.Body, stall})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), s.SHA256) {
+		err = fmt.Errorf("checksum mismatch")
+	}
+	if err == nil {
+		err = os.Rename(part, dest)
+	}
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+	return nil
+}
+
+// progressWriter counts the bytes written through it
+type progressWriter func(n int64)
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w(int64(len(p)))
+	return len(p), nil
+}
@@ -0,0 +1,61 @@
+package audio
+
+import (
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// EyeBreakInterval and EyeBreakMessage implement the 20-20-20 rule: every
+// 20 minutes, look at something 20 feet away for 20 seconds
+const (
+	EyeBreakInterval = 20 * time.Minute
+	EyeBreakMessage  = "Time for an eye break: look at something about 20 feet (6 m) away for 20 seconds."
+)
+
+// newChime returns a soft two-note bell that fades out in under two seconds
+func newChime(sampleRate beep.SampleRate) beep.Streamer {
+	notes := []struct {
+		freq  float64
+		start time.Duration
+	}{
+		{freq: 880, start: 0},
+		{freq: 1318.5, start: 250 * time.Millisecond},
+	}
+
+	const (
+		gain  = 0.15
+		decay = 0.4 // seconds for the note to fall to about a third
+	)
+
+	length := sampleRate.N(1800 * time.Millisecond)
+	pos := 0
+
+	return beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		if pos >= length {
+			return 0, false
+		}
+
+		for i := range samples {
+			if pos >= length {
+				break
+			}
+
+			var val float64
+			for _, note := range notes {
+				t := sampleRate.D(pos - sampleRate.N(note.start)).Seconds()
+				if t < 0 {
+					continue
+				}
+				val += gain * math.Exp(-t/decay) * math.Sin(2*math.Pi*note.freq*t)
+			}
+
+			samples[i][0] = val
+			samples[i][1] = val
+			pos++
+			n++
+		}
+		return n, true
+	})
+}
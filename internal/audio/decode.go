@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/flac"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/vorbis"
+	"github.com/faiface/beep/wav"
+)
+
+// decoder decodes an open audio file; the returned streamer owns the
+// file, and can only seek if the file can
+type decoder func(f io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error)
+
+var decoders = map[string]decoder{
+	".mp3":  func(f io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) { return mp3.Decode(f) },
+	".flac": func(f io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) { return flac.Decode(f) },
+	".wav":  decodeWAV,
+	".ogg":  func(f io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) { return vorbis.Decode(f) },
+}
+
+// decodeWAV decodes a WAV file at its full level. beep's decoder divides
+// 16- and 24-bit samples by 2^16 and 2^24, which plays them 6 dB quieter
+// than the other formats.
+func decodeWAV(f io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	streamer, format, err := wav.Decode(f)
+	if err != nil || format.Precision < 2 {
+		return streamer, format, err
+	}
+	return doubled{streamer}, format, nil
+}
+
+// doubled plays a streamer at twice its level
+type doubled struct {
+	beep.StreamSeekCloser
+}
+
+func (d doubled) Stream(samples [][2]float64) (int, bool) {
+	n, ok := d.StreamSeekCloser.Stream(samples)
+	for i := range samples[:n] {
+		samples[i][0] *= 2
+		samples[i][1] *= 2
+	}
+	return n, ok
+}
+
+// openAudio opens and decodes an audio file. The format is sniffed from
+// the file header, so misnamed files still play, falling back to the
+// extension when the header is not recognized.
+func openAudio(path string) (beep.StreamSeekCloser, beep.Format, error) {
+	f, err := openSoundFile(path)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	ext, err := sniffFormat(f)
+	if err != nil {
+		f.Close()
+		return nil, beep.Format{}, err
+	}
+	if ext == "" {
+		ext = strings.ToLower(filepath.Ext(path))
+	}
+
+	decode, ok := decoders[ext]
+	if !ok {
+		f.Close()
+		return nil, beep.Format{}, fmt.Errorf("unsupported audio format: %s", filepath.Base(path))
+	}
+
+	streamer, format, err := decode(f)
+	if err != nil {
+		f.Close()
+		return nil, beep.Format{}, err
+	}
+	if err := probeSound(streamer); err != nil {
+		streamer.Close()
+		return nil, beep.Format{}, err
+	}
+	return streamer, format, nil
+}
+
+// openSoundFile opens a sound from the sounds folder or the starter pack
+func openSoundFile(path string) (io.ReadSeekCloser, error) {
+	if !isEmbeddedSound(path) {
+		return os.Open(path)
+	}
+	if StarterSounds == nil {
+		return nil, fmt.Errorf("no starter sounds: %s", path)
+	}
+	f, err := StarterSounds.Open(strings.TrimPrefix(path, embeddedPrefix))
+	if err != nil {
+		return nil, err
+	}
+	rs, ok := f.(io.ReadSeekCloser)
+	if !ok {
+		f.Close()
+		return nil, fmt.Errorf("starter sound can't seek: %s", path)
+	}
+	return rs, nil
+}
+
+// statSoundFile describes a sound from the sounds folder or the starter
+// pack, whose files only change with the app
+func statSoundFile(path string) (fs.FileInfo, error) {
+	if !isEmbeddedSound(path) {
+		return os.Stat(path)
+	}
+	if StarterSounds == nil {
+		return nil, fmt.Errorf("no starter sounds: %s", path)
+	}
+	return fs.Stat(StarterSounds, strings.TrimPrefix(path, embeddedPrefix))
+}
+
+// sniffFormat returns the extension matching the file's magic bytes, or
+// an empty string if unknown, and rewinds the file
+func sniffFormat(f io.ReadSeeker) (string, error) {
+	header := make([]byte, 12)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return headerFormat(header[:n]), nil
+}
+
+// headerFormat returns the extension matching the first bytes of an audio
+// file, or an empty string if unknown
+func headerFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return ".flac"
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return ".ogg"
+	case len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return ".wav"
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return ".mp3"
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		// MPEG audio frame sync
+		return ".mp3"
+	}
+	return ""
+}
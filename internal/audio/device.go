@@ -0,0 +1,130 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// DeviceChange describes a change of the default output device
+type DeviceChange struct {
+	// ID is the endpoint ID of the new default device, empty if there is none
+	ID string
+	// previousRemoved is set when the old default device went away, e.g.
+	// headphones were unplugged or a Bluetooth headset disconnected
+	previousRemoved bool
+	// headphones is set when the new default device is headphones or a headset
+	headphones bool
+}
+
+// OutputPolicy decides how playback reacts to output device changes
+type OutputPolicy struct {
+	Follow            bool // reopen the speaker on the new default device
+	PauseOnDisconnect bool // pause when the previous device went away
+	HeadphonesOnly    bool // only play while headphones are the output
+
+	OnHeadphones bool // whether the current output is headphones
+	Held         bool // playback was stopped until headphones return
+}
+
+// Allowed reports whether playback is allowed on the current output
+func (p *OutputPolicy) Allowed() bool {
+	return !p.HeadphonesOnly || p.OnHeadphones
+}
+
+// CanPlay reports whether playback is allowed on the current output, and
+// otherwise remembers to start once headphones return. It is for a play
+// that is about to happen; use Allowed to only ask.
+func (p *OutputPolicy) CanPlay() bool {
+	if !p.Allowed() {
+		p.Held = true
+		return false
+	}
+	return true
+}
+
+// Handle applies the policy to a change of the default output device
+func (p *OutputPolicy) Handle(sp *Player, change DeviceChange) {
+	p.OnHeadphones = change.headphones
+	// Whatever happens now, a later play must not reuse the old device
+	sp.outputChanged()
+
+	switch {
+	case p.HeadphonesOnly && !change.headphones:
+		if sp.IsPlaying() {
+			sp.Pause()
+			p.Held = true
+		}
+	case p.HeadphonesOnly && p.Held:
+		p.Held = false
+		sp.Play()
+	case change.previousRemoved && p.PauseOnDisconnect:
+		// Don't carry on through whatever the OS fell back to
+		sp.Pause()
+	case p.Follow && sp.Device == "":
+		// A device picked from the tray stays in use
+		sp.reopen()
+	}
+}
+
+// DeviceVolumes remembers the volume picked for each output device, so
+// headphones and speakers each come back at their own level
+type DeviceVolumes struct {
+	path    string
+	current string
+	volumes map[string]float64
+}
+
+// LoadDeviceVolumes reads the remembered volumes from path
+func LoadDeviceVolumes(path, currentID string) *DeviceVolumes {
+	d := &DeviceVolumes{
+		path:    path,
+		current: currentID,
+		volumes: make(map[string]float64),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Reading device volumes failed", "err", err)
+		}
+		return d
+	}
+	if err := json.Unmarshal(data, &d.volumes); err != nil {
+		logger.Error("Parsing device volumes failed", "err", err)
+	}
+	return d
+}
+
+// Lookup returns the volume remembered for the current device
+func (d *DeviceVolumes) Lookup() (float64, bool) {
+	if d.current == "" {
+		return 0, false
+	}
+	volume, ok := d.volumes[d.current]
+	return volume, ok
+}
+
+// Remember stores the volume for the current device
+func (d *DeviceVolumes) Remember(volume float64) {
+	if d.current == "" {
+		return
+	}
+	d.volumes[d.current] = volume
+
+	data, err := json.MarshalIndent(d.volumes, "", "  ")
+	if err == nil {
+		os.MkdirAll(filepath.Dir(d.path), 0o755)
+		err = os.WriteFile(d.path, data, 0o644)
+	}
+	if err != nil {
+		logger.Error("Saving device volumes failed", "err", err)
+	}
+}
+
+// SwitchTo makes id the current device and returns its remembered volume
+func (d *DeviceVolumes) SwitchTo(id string) (float64, bool) {
+	d.current = id
+	return d.Lookup()
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package audio
+
+import "time"
+
+// WatchDefaultDevice returns a channel that never fires; outside Windows the
+// speaker plays through the system's default device, which the sound server
+// already moves between outputs
+func WatchDefaultDevice(interval time.Duration) <-chan DeviceChange {
+	return nil
+}
+
+// DefaultOutput reports no device ID and headphones, since the output
+// can't be inspected here and the headphones-only policy must not block
+// playback forever
+func DefaultOutput() (id string, headphones bool) {
+	return "", true
+}
@@ -0,0 +1,115 @@
+package audio
+
+import (
+	"runtime"
+	"time"
+	"unsafe"
+
+	"rogverse.fyi/ambiantgo/internal/winapi"
+)
+
+// WatchDefaultDevice polls the default output endpoint and reports on the
+// returned channel whenever it changes, e.g. after docking or plugging in
+// a headset
+func WatchDefaultDevice(interval time.Duration) <-chan DeviceChange {
+	changed := make(chan DeviceChange, 4)
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		uninit, err := winapi.ComInit()
+		if err != nil {
+			logger.Error("Watching the output device failed", "err", err)
+			return
+		}
+		defer uninit()
+
+		enumerator, err := newDeviceEnumerator()
+		if err != nil {
+			logger.Error("Watching the output device failed", "err", err)
+			return
+		}
+		defer winapi.ComRelease(enumerator)
+
+		current := defaultDeviceID(enumerator)
+		for range time.Tick(interval) {
+			id := defaultDeviceID(enumerator)
+			if id == current {
+				continue
+			}
+
+			change := DeviceChange{
+				ID:              id,
+				previousRemoved: current != "" && !deviceActive(enumerator, current),
+				headphones:      id != "" && deviceIsHeadphones(enumerator, id),
+			}
+			current = id
+			select {
+			case changed <- change:
+			default:
+			}
+		}
+	}()
+
+	return changed
+}
+
+// defaultDeviceID returns the ID of the default output endpoint, or an empty
+// string if there is none (e.g. every device is unplugged)
+func defaultDeviceID(enumerator unsafe.Pointer) string {
+	device, err := defaultOutputDevice(enumerator)
+	if err != nil {
+		return ""
+	}
+	defer winapi.ComRelease(device)
+
+	id, err := deviceID(device)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// deviceActive reports whether the endpoint with the given ID is still
+// present and enabled
+func deviceActive(enumerator unsafe.Pointer, id string) bool {
+	device, err := deviceByID(enumerator, id)
+	if err != nil {
+		return false
+	}
+	defer winapi.ComRelease(device)
+
+	state, err := deviceState(device)
+	return err == nil && state == deviceStateActive
+}
+
+// deviceIsHeadphones reports whether an endpoint is headphones or a headset
+func deviceIsHeadphones(enumerator unsafe.Pointer, id string) bool {
+	device, err := deviceByID(enumerator, id)
+	if err != nil {
+		return false
+	}
+	defer winapi.ComRelease(device)
+
+	formFactor, err := deviceFormFactor(device)
+	if err != nil {
+		return false
+	}
+	return formFactor == formFactorHeadphones || formFactor == formFactorHeadset
+}
+
+// DefaultOutput returns the ID of the current default output device and
+// whether it is headphones or a headset
+func DefaultOutput() (id string, headphones bool) {
+	err := withDeviceEnumerator(func(enumerator unsafe.Pointer) error {
+		if id = defaultDeviceID(enumerator); id != "" {
+			headphones = deviceIsHeadphones(enumerator, id)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("Checking the output device failed", "err", err)
+	}
+	return id, headphones
+}
@@ -0,0 +1,123 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/faiface/beep"
+)
+
+// EQ is the gain of the equalizer's three bands in dB: a low shelf, a
+// peak in the middle and a high shelf
+type EQ struct {
+	Low  float64 `yaml:"low" json:"low"`
+	Mid  float64 `yaml:"mid" json:"mid"`
+	High float64 `yaml:"high" json:"high"`
+}
+
+// Frequencies of the equalizer's bands in Hz
+const (
+	eqLowFreq  = 250
+	eqMidFreq  = 1000
+	eqMidQ     = 0.8
+	eqHighFreq = 4000
+)
+
+// EQPresets are the settings offered in the Equalizer menu
+var EQPresets = []struct {
+	Name string
+	EQ   EQ
+}{
+	{"Flat", EQ{}},
+	{"Warm", EQ{Low: 3, High: -4}},
+	{"Bright", EQ{Low: -2, High: 4}},
+	{"Rumble cut", EQ{Low: -12}},
+}
+
+func (eq EQ) String() string {
+	return fmt.Sprintf("%g %g %g", eq.Low, eq.Mid, eq.High)
+}
+
+// ParseEQ reads the three gains in dB as written by String, e.g. "3 0 -4"
+func ParseEQ(s string) (EQ, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return EQ{}, fmt.Errorf("expected three gains in dB, low mid high")
+	}
+	var gains [3]float64
+	for i, f := range fields {
+		gain, err := strconv.ParseFloat(strings.TrimSuffix(f, "dB"), 64)
+		if err != nil || gain < -24 || gain > 12 {
+			return EQ{}, fmt.Errorf("invalid gain %q, expected -24 to 12 dB", f)
+		}
+		gains[i] = gain
+	}
+	return EQ{Low: gains[0], Mid: gains[1], High: gains[2]}, nil
+}
+
+// equalizer filters the mix through the three bands, or passes it through
+// untouched while they are all flat
+type equalizer struct {
+	streamer       beep.Streamer
+	low, mid, high *biquad
+	sampleRate     beep.SampleRate
+	flat           bool
+}
+
+func newEqualizer(s beep.Streamer, sampleRate beep.SampleRate, eq EQ) *equalizer {
+	e := &equalizer{streamer: s, sampleRate: sampleRate, flat: eq == EQ{}}
+	e.low, e.mid, e.high = e.filters(eq)
+	return e
+}
+
+func (e *equalizer) filters(eq EQ) (low, mid, high *biquad) {
+	return lowShelf(e.sampleRate, eqLowFreq, eq.Low),
+		peaking(e.sampleRate, eqMidFreq, eq.Mid, eqMidQ),
+		highShelf(e.sampleRate, eqHighFreq, eq.High)
+}
+
+// set changes the bands while the mix plays
+func (e *equalizer) set(eq EQ) {
+	low, mid, high := e.filters(eq)
+	e.low.retune(low)
+	e.mid.retune(mid)
+	e.high.retune(high)
+	e.flat = eq == EQ{}
+}
+
+func (e *equalizer) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = e.streamer.Stream(samples)
+	if e.flat {
+		// Flat filters give back their input, so the last samples are
+		// all the state they need to pick up from when a band changes
+		for i := max(n-2, 0); i < n; i++ {
+			for ch, x := range samples[i] {
+				e.low.pass(x, ch)
+				e.mid.pass(x, ch)
+				e.high.pass(x, ch)
+			}
+		}
+		return n, ok
+	}
+	for i := range samples[:n] {
+		for ch, x := range samples[i] {
+			samples[i][ch] = e.high.process(e.mid.process(e.low.process(x, ch), ch), ch)
+		}
+	}
+	return n, ok
+}
+
+func (e *equalizer) Err() error {
+	return e.streamer.Err()
+}
+
+// SetEQ changes the equalizer of the mix, live if playing
+func (sp *Player) SetEQ(eq EQ) {
+	sp.EQ = eq
+	if sp.equalizer != nil {
+		sp.out.Lock()
+		sp.equalizer.set(eq)
+		sp.out.Unlock()
+	}
+}
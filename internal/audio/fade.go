@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"github.com/faiface/beep"
+)
+
+// Fader ramps the gain of a streamer towards a target, so playback can
+// start and stop smoothly instead of cutting in or out
+type Fader struct {
+	Streamer beep.Streamer
+
+	gain   float64 // current gain, from 0 (silent) to 1
+	target float64
+	step   float64 // gain change per sample
+	stop   bool    // end the stream once the gain reaches 0
+	onStop func()  // called once the stream ended that way
+}
+
+// newFader wraps s, starting at the given gain
+func newFader(s beep.Streamer, gain float64) *Fader {
+	return &Fader{Streamer: s, gain: gain, target: gain}
+}
+
+// fadeTo moves the gain to target over the given number of samples; with
+// stop, the stream ends once it is silent
+func (f *Fader) fadeTo(target float64, samples int, stop bool) {
+	f.target = target
+	f.stop = stop
+	if samples <= 0 {
+		f.gain = target
+		return
+	}
+	f.step = (target - f.gain) / float64(samples)
+	if f.step < 0 {
+		f.step = -f.step
+	}
+}
+
+func (f *Fader) Stream(samples [][2]float64) (n int, ok bool) {
+	if f.stop && f.gain == 0 {
+		if f.onStop != nil {
+			f.onStop()
+			f.onStop = nil
+		}
+		return 0, false
+	}
+
+	n, ok = f.Streamer.Stream(samples)
+	for i := range samples[:n] {
+		switch {
+		case f.gain < f.target:
+			f.gain = min(f.gain+f.step, f.target)
+		case f.gain > f.target:
+			f.gain = max(f.gain-f.step, f.target)
+		}
+
+		// Squaring the gain makes the fade sound even to the ear
+		g := f.gain * f.gain
+		samples[i][0] *= g
+		samples[i][1] *= g
+	}
+	return n, ok
+}
+
+func (f *Fader) Err() error {
+	return f.Streamer.Err()
+}
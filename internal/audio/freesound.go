@@ -0,0 +1,144 @@
+package audio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// freesoundSearchURL is the text search of the Freesound API
+const freesoundSearchURL = "https://freesound.org/apiv2/search/text/"
+
+// FreesoundResults is how many results a search shows
+const FreesoundResults = 10
+
+// maxPreviewSize caps the preview downloaded to listen to
+const maxPreviewSize = 20 << 20
+
+// FreesoundSound is a search result from freesound.org
+type FreesoundSound struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Username string  `json:"username"`
+	License  string  `json:"license"` // URL of the license
+	URL      string  `json:"url"`     // page of the sound
+	Duration float64 `json:"duration"`
+	Previews struct {
+		HQMP3 string `json:"preview-hq-mp3"`
+	} `json:"previews"`
+}
+
+// freesoundClient gives up on a search or download that hangs
+var freesoundClient = &http.Client{Timeout: 2 * time.Minute}
+
+// SearchFreesound returns the Creative Commons sounds matching query,
+// using an API key from freesound.org/apiv2/apply
+func SearchFreesound(token, query string) ([]FreesoundSound, error) {
+	params := url.Values{
+		"query":     {query},
+		"token":     {token},
+		"fields":    {"id,name,username,license,url,duration,previews"},
+		"page_size": {fmt.Sprint(FreesoundResults * 2)},
+	}
+	resp, err := freesoundClient.Get(freesoundSearchURL + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Freesound answered %s", resp.Status)
+	}
+
+	var page struct {
+		Results []FreesoundSound `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("parsing Freesound results: %w", err)
+	}
+
+	var sounds []FreesoundSound
+	for _, s := range page.Results {
+		if strings.Contains(s.License, "creativecommons.org") && s.Previews.HQMP3 != "" && len(sounds) < FreesoundResults {
+			sounds = append(sounds, s)
+		}
+	}
+	return sounds, nil
+}
+
+// Title names the sound for the menu, e.g. "Thunder close (0:42, by jdoe)"
+func (s FreesoundSound) Title() string {
+	d := time.Duration(s.Duration) * time.Second
+	return fmt.Sprintf("%s (%d:%02d, by %s)", s.baseName(), int(d.Minutes()), int(d.Seconds())%60, s.Username)
+}
+
+// baseName is the name of the sound without the extension uploaders
+// often leave in it
+func (s FreesoundSound) baseName() string {
+	name := strings.TrimSuffix(s.Name, filepath.Ext(s.Name))
+	if isSupportedSound(s.Name) || name == "" {
+		return strings.TrimSpace(name)
+	}
+	return strings.TrimSpace(s.Name)
+}
+
+// Preview downloads the preview to listen to before adding the sound
+func (s FreesoundSound) Preview() ([]byte, error) {
+	resp, err := freesoundClient.Get(s.Previews.HQMP3)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Freesound answered %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxPreviewSize))
+}
+
+// Download saves the sound into dir as an MP3, with its author, license
+// and page in the sound's metadata file next to it, and returns its path.
+// The original upload needs a Freesound login, so the high quality
+// preview is saved.
+func (s FreesoundSound) Download(dir string) (string, error) {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, s.baseName())
+	dest := filepath.Join(dir, name+".mp3")
+	if _, err := os.Stat(dest); err == nil {
+		dest = filepath.Join(dir, fmt.Sprintf("%s (%d).mp3", name, s.ID))
+	}
+
+	data, err := s.Preview()
+	if err != nil {
+		return "", err
+	}
+	if headerFormat(data) != ".mp3" {
+		return "", fmt.Errorf("Freesound sent something other than an MP3")
+	}
+
+	// The credits go in first, so the sound is never listed without them
+	meta, err := json.MarshalIndent(SoundMeta{Author: s.Username, License: s.License, Source: s.URL}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(dest+".json", meta, 0o644); err != nil {
+		return "", err
+	}
+	part := dest + ".part"
+	if err := os.WriteFile(part, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(part, dest); err != nil {
+		os.Remove(part)
+		return "", err
+	}
+	return dest, nil
+}
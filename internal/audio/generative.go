@@ -0,0 +1,124 @@
+package audio
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+)
+
+// Generator builds an endless soundscape out of short clips (waves, bird
+// calls, distant traffic) by playing them at random intervals with random
+// gain and pan, so the result never repeats the same way twice
+type Generator struct {
+	clips  []*beep.Buffer
+	format beep.Format
+	mixer  beep.Mixer
+
+	minGap, maxGap time.Duration
+	untilNext      int
+	lastClip       int
+}
+
+// newGenerator decodes every clip in dir, resampling them all to the
+// sample rate of the first one
+func newGenerator(dir string) (*Generator, error) {
+	var matches []string
+	for _, path := range scanSounds(dir) {
+		if isSupportedSound(path) {
+			matches = append(matches, path)
+		}
+	}
+
+	g := &Generator{
+		minGap:   2 * time.Second,
+		maxGap:   12 * time.Second,
+		lastClip: -1,
+	}
+
+	for _, path := range matches {
+		buffer, err := g.decodeClip(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading clip %s: %w", filepath.Base(path), err)
+		}
+		g.clips = append(g.clips, buffer)
+	}
+
+	if len(g.clips) == 0 {
+		return nil, fmt.Errorf("no clips found in %s", dir)
+	}
+	return g, nil
+}
+
+func (g *Generator) decodeClip(path string) (*beep.Buffer, error) {
+	streamer, format, err := openAudio(path)
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+
+	// The first clip decides the format of the whole soundscape
+	if g.format.SampleRate == 0 {
+		g.format = format
+	}
+
+	buffer := beep.NewBuffer(g.format)
+	if format.SampleRate == g.format.SampleRate {
+		buffer.Append(streamer)
+	} else {
+		buffer.Append(beep.Resample(4, format.SampleRate, g.format.SampleRate, streamer))
+	}
+	return buffer, nil
+}
+
+// Stream mixes the currently sounding clips, starting a new one whenever
+// the gap to the next clip has passed. It never ends.
+func (g *Generator) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		if g.untilNext <= 0 {
+			g.startClip()
+		}
+
+		chunk := len(samples) - n
+		if chunk > g.untilNext {
+			chunk = g.untilNext
+		}
+
+		g.mixer.Stream(samples[n : n+chunk])
+		g.untilNext -= chunk
+		n += chunk
+	}
+	return n, true
+}
+
+func (g *Generator) Err() error {
+	return nil
+}
+
+// startClip adds a random clip, other than the previous one when possible,
+// and picks the gap until the next clip starts
+func (g *Generator) startClip() {
+	i := rand.Intn(len(g.clips))
+	if len(g.clips) > 1 && i == g.lastClip {
+		i = (i + 1 + rand.Intn(len(g.clips)-1)) % len(g.clips)
+	}
+	g.lastClip = i
+
+	clip := g.clips[i]
+	pan := &effects.Pan{
+		Streamer: clip.Streamer(0, clip.Len()),
+		Pan:      rand.Float64()*1.6 - 0.8,
+	}
+	volume := &effects.Volume{
+		Streamer: pan,
+		Base:     2,
+		Volume:   -rand.Float64() * 2,
+	}
+	g.mixer.Add(volume)
+
+	gap := g.minGap + time.Duration(rand.Int63n(int64(g.maxGap-g.minGap)))
+	g.untilNext = g.format.SampleRate.N(gap)
+}
@@ -0,0 +1,120 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// Stretcher turns a short recording into an endless texture: it plays
+// overlapping, windowed grains taken from a playhead that moves through
+// the recording many times slower than real time
+type Stretcher struct {
+	data    [][2]float64
+	window  []float64
+	hop     int
+	stretch float64
+
+	head      float64
+	grains    []grain
+	untilNext int
+}
+
+type grain struct {
+	start, pos int
+}
+
+// grainOverlap is how many grains sound at once; Hann windows overlapping
+// this many times add up to grainOverlap/2
+const grainOverlap = 4
+
+// newStretcher slows data down by the stretch factor, e.g. 8 makes a
+// 10-second recording take 80 seconds to move through before wrapping
+func newStretcher(data [][2]float64, sampleRate beep.SampleRate, stretch float64) *Stretcher {
+	size := sampleRate.N(250 * time.Millisecond)
+	if size > len(data)/2 {
+		size = len(data) / 2
+	}
+	if size < grainOverlap {
+		size = grainOverlap
+	}
+
+	window := make([]float64, size)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size))
+	}
+
+	return &Stretcher{
+		data:    data,
+		window:  window,
+		hop:     size / grainOverlap,
+		stretch: stretch,
+	}
+}
+
+// Stream never ends
+func (s *Stretcher) Stream(samples [][2]float64) (n int, ok bool) {
+	if len(s.data) == 0 {
+		return 0, false
+	}
+
+	norm := 2.0 / grainOverlap
+	for i := range samples {
+		if s.untilNext <= 0 {
+			s.startGrain()
+			s.untilNext = s.hop
+		}
+		s.untilNext--
+
+		var left, right float64
+		active := s.grains[:0]
+		for _, g := range s.grains {
+			w := s.window[g.pos]
+			sample := s.data[(g.start+g.pos)%len(s.data)]
+			left += sample[0] * w
+			right += sample[1] * w
+
+			g.pos++
+			if g.pos < len(s.window) {
+				active = append(active, g)
+			}
+		}
+		s.grains = active
+
+		samples[i][0] = left * norm
+		samples[i][1] = right * norm
+	}
+	return len(samples), true
+}
+
+func (s *Stretcher) Err() error {
+	return nil
+}
+
+// startGrain starts a grain near the playhead, jittered so repeated
+// grains don't phase against each other, and advances the playhead
+func (s *Stretcher) startGrain() {
+	jitter := rand.Intn(2*s.hop+1) - s.hop
+	start := (int(s.head) + jitter + len(s.data)) % len(s.data)
+	s.grains = append(s.grains, grain{start: start})
+
+	s.head += float64(s.hop) / s.stretch
+	if s.head >= float64(len(s.data)) {
+		s.head -= float64(len(s.data))
+	}
+}
+
+// readSamples decodes a whole streamer into memory
+func readSamples(s beep.Streamer) [][2]float64 {
+	var data [][2]float64
+	buf := make([][2]float64, 4096)
+	for {
+		n, ok := s.Stream(buf)
+		data = append(data, buf[:n]...)
+		if !ok {
+			return data
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package audio
+
+import (
+	"strings"
+	"time"
+)
+
+// ListeningGuard tracks how long playback has continuously stayed at or
+// above a loud volume and raises a hearing-safety reminder past a limit
+type ListeningGuard struct {
+	Threshold float64       // effective volume counted as loud
+	Limit     time.Duration // loud listening time before the reminder
+	Reduce    bool          // lower the volume below threshold on reminder
+
+	loudSince time.Time
+	warned    bool
+}
+
+// Check updates the tracked listening time and reports whether the
+// reminder is due now. It fires once per continuous loud stretch; muted
+// playback is silent, so it ends one.
+func (g *ListeningGuard) Check(sp *Player, now time.Time) bool {
+	if g.Limit <= 0 || !sp.IsPlaying() || sp.Muted || sp.baseline+sp.Volume < g.Threshold {
+		g.loudSince = time.Time{}
+		g.warned = false
+		return false
+	}
+
+	if g.loudSince.IsZero() {
+		g.loudSince = now
+	}
+	if g.warned || now.Sub(g.loudSince) < g.Limit {
+		return false
+	}
+
+	g.warned = true
+	if g.Reduce {
+		sp.SetVolume(g.Threshold - sp.baseline - 1)
+	}
+	return true
+}
+
+// Message describes the reminder shown to the user
+func (g *ListeningGuard) Message() string {
+	msg := "You have been listening at a high volume for " + ShortDuration(g.Limit) + ". Consider turning it down or taking a break."
+	if g.Reduce {
+		msg += " The volume has been lowered."
+	}
+	return msg
+}
+
+// ShortDuration formats d without zero minutes and seconds, e.g. "2h"
+func ShortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,42 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGuardWarnsAfterLimit(t *testing.T) {
+	sp, _ := newNoisePlayer(t)
+	sp.SetVolume(0)
+	sp.Play()
+	g := &ListeningGuard{Threshold: -1, Limit: time.Hour, Reduce: true}
+
+	start := time.Now()
+	if g.Check(sp, start) || g.Check(sp, start.Add(59*time.Minute)) {
+		t.Fatal("reminder before the limit")
+	}
+	if !g.Check(sp, start.Add(time.Hour)) {
+		t.Fatal("no reminder at the limit")
+	}
+	if sp.Volume >= g.Threshold {
+		t.Errorf("volume is %v after the reminder, want it below %v", sp.Volume, g.Threshold)
+	}
+}
+
+func TestGuardIgnoresMutedPlayback(t *testing.T) {
+	sp, _ := newNoisePlayer(t)
+	sp.SetVolume(0)
+	sp.SetMuted(true)
+	sp.Play()
+	g := &ListeningGuard{Threshold: -1, Limit: time.Hour, Reduce: true}
+
+	start := time.Now()
+	for at := time.Duration(0); at <= 2*time.Hour; at += time.Minute {
+		if g.Check(sp, start.Add(at)) {
+			t.Fatalf("reminder after %v muted", at)
+		}
+	}
+	if sp.Volume != 0 {
+		t.Errorf("volume is %v after muted listening, want it unchanged at 0", sp.Volume)
+	}
+}
@@ -0,0 +1,131 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WatchImportFolder polls dir for new sound files, checks that they decode
+// and moves them into soundsDir, or into its folder for category such as
+// "Nature/Rain" when one is given, where the library watcher picks them
+// up. Imported sounds are measured right away when analysis is not nil.
+func WatchImportFolder(dir, soundsDir, category string, analysis *AnalysisCache, interval time.Duration) {
+	go func() {
+		// Files are only imported once their size stopped changing between
+		// two polls, so half-copied files are left alone
+		sizes := make(map[string]int64)
+		rejected := make(map[string]int64)
+
+		for range time.Tick(interval) {
+			matches, err := filepath.Glob(filepath.Join(dir, "*"))
+			if err != nil {
+				logger.Error("Scanning the import folder failed", "err", err)
+				continue
+			}
+
+			seen := make(map[string]int64)
+			for _, path := range matches {
+				if !isSupportedSound(path) {
+					continue
+				}
+				info, err := os.Stat(path)
+				if err != nil || info.IsDir() {
+					continue
+				}
+
+				if size, ok := rejected[path]; ok && size == info.Size() {
+					continue
+				}
+				if size, ok := sizes[path]; !ok || size != info.Size() || size == 0 {
+					seen[path] = info.Size()
+					continue
+				}
+
+				dest, err := importSound(path, soundsDir, category)
+				if err != nil {
+					logger.Error("Importing a sound failed", "file", path, "err", err)
+					rejected[path] = info.Size()
+					continue
+				}
+				if analysis != nil {
+					// Measuring starts on the first lookup
+					analysis.lookup(dest)
+				}
+			}
+			sizes = seen
+		}
+	}()
+}
+
+// importSound validates a sound file and moves it into soundsDir, or into
+// the folder of category when it is not empty
+func importSound(path, soundsDir, category string) (string, error) {
+	if err := checkSound(path); err != nil {
+		return "", err
+	}
+
+	dir := soundsDir
+	if category != "" {
+		var err error
+		if dir, err = makeCategoryFolder(soundsDir, category); err != nil {
+			return "", err
+		}
+	}
+	dest := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Stat(dest); err == nil {
+		return "", fmt.Errorf("%s already exists", dest)
+	}
+
+	if err := moveFile(path, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// checkSound makes sure a file can be decoded before it is added
+func checkSound(path string) error {
+	streamer, _, err := openAudio(path)
+	if err != nil {
+		return err
+	}
+	defer streamer.Close()
+
+	if streamer.Len() == 0 {
+		return errors.New("sound is empty")
+	}
+	return nil
+}
+
+// moveFile renames src to dest, copying when they are on different drives
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		in.Close()
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	in.Close()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SoundCategory is a group of sounds shown as one submenu, e.g. "Nature".
+// Nested categories are separated by slashes, e.g. "Nature/Forest".
+type SoundCategory struct {
+	Name   string
+	Sounds []string
+}
+
+// Categories of the built-in sounds and streams
+const (
+	noiseCategory  = "Noise"
+	toneCategory   = "Binaural beats"
+	streamCategory = "Radio"
+)
+
+// Category returns the category a sound is listed under, or an empty
+// string for the top of the Sounds menu. The category in a sound's
+// metadata wins over the category folder it is in.
+func (sp *Player) Category(path string) string {
+	switch {
+	case isNoiseSound(path):
+		return noiseCategory
+	case isToneSound(path):
+		return toneCategory
+	case IsStream(path):
+		return streamCategory
+	case isEmbeddedSound(path):
+		return ""
+	}
+	if category := strings.Trim(loadSoundMeta(path).Category, "/"); category != "" {
+		return category
+	}
+	rel, err := filepath.Rel(sp.SoundsDir, filepath.Dir(path))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Index groups the library by category for the Sounds menu: the sounds
+// without one first, then the categories by name, each in library order
+func (sp *Player) Index() []SoundCategory {
+	byName := make(map[string]*SoundCategory)
+	index := []*SoundCategory{{}}
+	byName[""] = index[0]
+	for _, sound := range sp.Sounds {
+		name := sp.Category(sound)
+		c, ok := byName[name]
+		if !ok {
+			c = &SoundCategory{Name: name}
+			byName[name] = c
+			index = append(index, c)
+		}
+		c.Sounds = append(c.Sounds, sound)
+	}
+	sort.SliceStable(index[1:], func(i, j int) bool {
+		return strings.ToLower(index[1+i].Name) < strings.ToLower(index[1+j].Name)
+	})
+
+	categories := make([]SoundCategory, len(index))
+	for i, c := range index {
+		categories[i] = *c
+	}
+	return categories
+}
@@ -0,0 +1,281 @@
+package audio
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultSoundsDir is the folder sounds are loaded from unless -sounds says otherwise
+const DefaultSoundsDir = "sounds"
+
+// StarterSounds is the sound pack built into the app, listed after the
+// sounds folder; a file of the same name in the folder replaces a starter
+// sound
+var StarterSounds fs.FS
+
+// embeddedPrefix marks the sounds read from StarterSounds
+const embeddedPrefix = "embedded:"
+
+func isEmbeddedSound(path string) bool {
+	return strings.HasPrefix(path, embeddedPrefix)
+}
+
+// starterSounds lists the starter sounds that the sounds folder doesn't
+// replace
+func starterSounds(folder []string) []string {
+	if StarterSounds == nil {
+		return nil
+	}
+	entries, err := fs.ReadDir(StarterSounds, ".")
+	if err != nil {
+		logger.Error("Finding starter sounds failed", "err", err)
+		return nil
+	}
+
+	replaced := make(map[string]bool)
+	for _, sound := range folder {
+		replaced[strings.ToLower(filepath.Base(sound))] = true
+	}
+	var sounds []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && isSupportedSound(name) && !replaced[strings.ToLower(name)] {
+			sounds = append(sounds, embeddedPrefix+name)
+		}
+	}
+	return sounds
+}
+
+// SoundFile returns the file name of a sound as shown in the menu
+func SoundFile(path string) string {
+	return filepath.Base(strings.TrimPrefix(path, embeddedPrefix))
+}
+
+// isSupportedSound reports whether path has a decodable audio extension
+func isSupportedSound(path string) bool {
+	_, ok := decoders[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// categoryMarker is the file that makes a subfolder of the sounds folder
+// a category rather than a soundscape
+const categoryMarker = ".category"
+
+// isCategoryFolder reports whether a folder groups sounds into a category
+func isCategoryFolder(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, categoryMarker))
+	return err == nil
+}
+
+// makeCategoryFolder creates the folder of a category such as
+// "Nature/Rain" in dir, marking it and every folder above it as a
+// category, and returns its path
+func makeCategoryFolder(dir, category string) (string, error) {
+	category = filepath.Clean(filepath.FromSlash(category))
+	if !filepath.IsLocal(category) {
+		return "", fmt.Errorf("invalid category %q", category)
+	}
+	for _, name := range strings.Split(category, string(filepath.Separator)) {
+		dir = filepath.Join(dir, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+		if !isCategoryFolder(dir) {
+			if err := os.WriteFile(filepath.Join(dir, categoryMarker), nil, 0o644); err != nil {
+				return "", err
+			}
+		}
+	}
+	return dir, nil
+}
+
+// scanSounds lists the supported audio files in dir, followed by its
+// subfolders, which each hold clips for a generative soundscape, and
+// then the sounds of its category folders
+func scanSounds(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Error("Finding sounds failed", "dir", dir, "err", err)
+		return []string{}
+	}
+
+	var files, folders, categorized []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case entry.IsDir() && isCategoryFolder(path):
+			categorized = append(categorized, scanSounds(path)...)
+		case entry.IsDir():
+			folders = append(folders, path)
+		case isSupportedSound(path):
+			files = append(files, path)
+		}
+	}
+	return append(append(files, folders...), categorized...)
+}
+
+// categoryFolders lists the category folders in dir
+func categoryFolders(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var folders []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() && isCategoryFolder(path) {
+			folders = append(folders, path)
+		}
+	}
+	return folders
+}
+
+// watchFolders watches category folders, and the categories nested in them
+func watchFolders(watcher *fsnotify.Watcher, folders []string) {
+	for _, folder := range folders {
+		if err := watcher.Add(folder); err != nil {
+			logger.Error("Watching the sounds folder failed", "err", err)
+		}
+		watchFolders(watcher, categoryFolders(folder))
+	}
+}
+
+// watchTree watches a new folder and every folder in it
+func watchTree(watcher *fsnotify.Watcher, dir string) {
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				logger.Error("Watching the sounds folder failed", "err", err)
+			}
+		}
+		return nil
+	})
+}
+
+// librarySounds lists the sounds in dir, then the starter sounds, the
+// streams and the built-in ones
+func librarySounds(dir string) []string {
+	folder := scanSounds(dir)
+	sounds := append(append(folder, starterSounds(folder)...), streamSounds()...)
+	return append(append(sounds, noiseSounds()...), toneSounds()...)
+}
+
+// LibraryChange reports a sound appearing in or disappearing from the
+// sounds folder while the app is running
+type LibraryChange struct {
+	Path    string
+	Removed bool
+}
+
+// WatchSounds watches dir and reports sounds that are added or deleted.
+// New files are only reported once they stop changing, so a sound is not
+// listed while it is still being copied in.
+// Category folders are watched at any depth, including ones added later.
+func WatchSounds(dir string) <-chan LibraryChange {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error("Watching the sounds folder failed", "err", err)
+		return nil
+	}
+	if err := watcher.Add(dir); err != nil {
+		logger.Error("Watching the sounds folder failed", "err", err)
+		watcher.Close()
+		return nil
+	}
+	watchFolders(watcher, categoryFolders(dir))
+
+	changes := make(chan LibraryChange)
+	go func() {
+		defer watcher.Close()
+
+		pending := make(map[string]*time.Timer)
+		settled := make(chan string)
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				path := event.Name
+				switch {
+				case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+					if t, ok := pending[path]; ok {
+						t.Stop()
+						delete(pending, path)
+					}
+					changes <- LibraryChange{Path: path, Removed: true}
+				case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+					// A new folder may become a category once its marker
+					// is copied in, so it is watched from the start
+					if info, err := os.Stat(path); err == nil && info.IsDir() && event.Has(fsnotify.Create) {
+						watchTree(watcher, path)
+					}
+					if t, ok := pending[path]; ok {
+						t.Reset(time.Second)
+						continue
+					}
+					pending[path] = time.AfterFunc(time.Second, func() { settled <- path })
+				}
+			case path := <-settled:
+				delete(pending, path)
+				info, err := os.Stat(path)
+				if err != nil {
+					continue
+				}
+				switch parent := filepath.Dir(path); {
+				case parent != dir && !isCategoryFolder(parent):
+					// Clips of a soundscape aren't sounds of their own
+				case info.IsDir() && isCategoryFolder(path):
+					for _, sound := range scanSounds(path) {
+						changes <- LibraryChange{Path: sound}
+					}
+				case info.IsDir() || isSupportedSound(path):
+					changes <- LibraryChange{Path: path}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error("Watching the sounds folder failed", "err", err)
+			}
+		}
+	}()
+	return changes
+}
+
+// AddToLibrary lists a new sound, reporting false if it was already known
+// or can't be played
+func (sp *Player) AddToLibrary(path string) bool {
+	for _, sound := range sp.Sounds {
+		if sound == path {
+			return false
+		}
+	}
+	if err := checkLibrarySound(path); err != nil {
+		logger.Warn("Skipping a sound that can't be played", "sound", path, "err", err)
+		sp.failed(path, err)
+		return false
+	}
+	sp.Sounds = append(sp.Sounds, path)
+	return true
+}
+
+// RemoveFromLibrary drops a deleted sound from the list and the mix,
+// reporting false if it wasn't known
+func (sp *Player) RemoveFromLibrary(path string) bool {
+	for i, sound := range sp.Sounds {
+		if sound == path {
+			sp.Sounds = append(sp.Sounds[:i], sp.Sounds[i+1:]...)
+			sp.RemoveSound(path)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package audio
+
+import (
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// CrossfadeLoop loops a sound endlessly, fading the end of each pass into
+// the start of the next one so there is no click where the file restarts.
+// A pass plays the source up to overlap samples before its end, and those
+// last samples are mixed with the first overlap samples instead of
+// following them.
+type CrossfadeLoop struct {
+	streamer beep.StreamSeeker
+	head     [][2]float64 // first overlap samples of the source
+	bodyEnd  int          // source position where the crossfade starts
+
+	inHead  bool // playing the head from memory
+	fading  bool // mixing the head with the tail of the previous pass
+	headPos int
+	tmp     [][2]float64
+}
+
+// newCrossfadeLoop loops s with overlap samples of crossfade, at most half
+// the length of the sound, which must be at least two samples long. The
+// head is read into memory up front.
+func newCrossfadeLoop(s beep.StreamSeeker, overlap int) *CrossfadeLoop {
+	if overlap > s.Len()/2 {
+		overlap = s.Len() / 2
+	}
+
+	l := &CrossfadeLoop{
+		streamer: s,
+		bodyEnd:  s.Len() - overlap,
+		inHead:   true,
+	}
+
+	s.Seek(0)
+	l.head = make([][2]float64, overlap)
+	read := 0
+	for read < overlap {
+		n, ok := s.Stream(l.head[read:])
+		read += n
+		if !ok {
+			break
+		}
+	}
+	l.head = l.head[:read]
+	return l
+}
+
+// Stream never ends unless the source can't be read
+func (l *CrossfadeLoop) Stream(samples [][2]float64) (n int, ok bool) {
+	if len(l.head) == 0 {
+		return 0, false
+	}
+
+	for n < len(samples) {
+		if l.inHead {
+			n += l.streamHead(samples[n:])
+			continue
+		}
+
+		remaining := l.bodyEnd - l.streamer.Position()
+		if remaining <= 0 {
+			l.startFade()
+			continue
+		}
+		chunk := samples[n:]
+		if len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		sn, sok := l.streamer.Stream(chunk)
+		n += sn
+		if !sok {
+			if l.streamer.Err() != nil {
+				// A broken file ends the loop, which reports the error
+				return n, n > 0
+			}
+			// The decoder ended earlier than its length said
+			l.startFade()
+		}
+	}
+	return n, true
+}
+
+// startFade begins the next pass, mixing its head with the tail
+func (l *CrossfadeLoop) startFade() {
+	l.inHead = true
+	l.fading = true
+	l.headPos = 0
+}
+
+// streamHead plays the buffered head, crossfaded with the tail of the
+// source while fading, and returns how many samples it filled
+func (l *CrossfadeLoop) streamHead(samples [][2]float64) int {
+	n := len(l.head) - l.headPos
+	if n > len(samples) {
+		n = len(samples)
+	}
+
+	if !l.fading {
+		copy(samples, l.head[l.headPos:l.headPos+n])
+	} else {
+		if len(l.tmp) < n {
+			l.tmp = make([][2]float64, n)
+		}
+		tail := l.tmp[:n]
+
+		// A tail shorter than the head is padded with silence
+		tn, _ := l.streamer.Stream(tail)
+		for i := tn; i < n; i++ {
+			tail[i] = [2]float64{}
+		}
+
+		// Equal-power fade keeps the loudness steady across the seam
+		for i := 0; i < n; i++ {
+			t := float64(l.headPos+i) / float64(len(l.head)) * math.Pi / 2
+			in, out := math.Sin(t), math.Cos(t)
+			samples[i][0] = l.head[l.headPos+i][0]*in + tail[i][0]*out
+			samples[i][1] = l.head[l.headPos+i][1]*in + tail[i][1]*out
+		}
+	}
+
+	l.headPos += n
+	if l.headPos == len(l.head) {
+		l.inHead = false
+		if l.fading {
+			l.fading = false
+			l.streamer.Seek(len(l.head))
+		}
+	}
+	return n
+}
+
+func (l *CrossfadeLoop) Err() error {
+	return l.streamer.Err()
+}
+
+// Position returns the position within the current pass
+func (l *CrossfadeLoop) Position() int {
+	if l.inHead {
+		return l.headPos
+	}
+	return l.streamer.Position()
+}
+
+// Seek jumps to position p of a pass without crossfading
+func (l *CrossfadeLoop) Seek(p int) error {
+	l.fading = false
+	if p < len(l.head) {
+		l.inHead = true
+		l.headPos = p
+		return l.streamer.Seek(len(l.head))
+	}
+	l.inHead = false
+	return l.streamer.Seek(p)
+}
@@ -0,0 +1,130 @@
+package audio
+
+import (
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// LoudnessTarget is the loudness every sound file is brought to when
+// normalizing, in LUFS
+var LoudnessTarget = -18.0
+
+// maxPeak keeps normalized sounds 1 dB below clipping
+const maxPeak = 0.891
+
+// loudnessMeter measures integrated loudness as EBU R128 defines it: the
+// signal is K-weighted, its power taken over 400 ms blocks overlapping by
+// 75%, and blocks that are silent or far quieter than the rest are gated
+// out.
+type loudnessMeter struct {
+	shelf, highPass *biquad
+	blockSize       int       // samples in 100 ms
+	sum             float64   // power of the 100 ms being measured
+	n               int       // samples in it
+	quarters        []float64 // power of each 100 ms so far
+	peak            float64   // highest sample level
+}
+
+func newLoudnessMeter(sampleRate beep.SampleRate) *loudnessMeter {
+	rate := float64(sampleRate)
+
+	// The K-weighting filters, with the coefficients of libebur128 so
+	// they match the standard at any sample rate
+	k := math.Tan(math.Pi * 1681.974450955533 / rate)
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	q := 0.7071752369554196
+	shelf := (&biquad{
+		b0: vh + vb*k/q + k*k,
+		b1: 2 * (k*k - vh),
+		b2: vh - vb*k/q + k*k,
+		a1: 2 * (k*k - 1),
+		a2: 1 - k/q + k*k,
+	}).normalize(1 + k/q + k*k)
+
+	k = math.Tan(math.Pi * 38.13547087602444 / rate)
+	q = 0.5003270373238773
+	a0 := 1 + k/q + k*k
+	highPass := &biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return &loudnessMeter{
+		shelf:     shelf,
+		highPass:  highPass,
+		blockSize: sampleRate.N(100 * time.Millisecond),
+	}
+}
+
+// add measures more samples
+func (m *loudnessMeter) add(samples [][2]float64) {
+	for _, s := range samples {
+		for ch, x := range s {
+			m.peak = math.Max(m.peak, math.Abs(x))
+			y := m.highPass.process(m.shelf.process(x, ch), ch)
+			m.sum += y * y
+		}
+		m.n++
+		if m.n == m.blockSize {
+			m.quarters = append(m.quarters, m.sum/float64(m.n))
+			m.sum, m.n = 0, 0
+		}
+	}
+}
+
+// loudness returns the integrated loudness in LUFS, or -Inf for a sound
+// too short or too quiet to measure
+func (m *loudnessMeter) loudness() float64 {
+	var blocks []float64
+	for i := 3; i < len(m.quarters); i++ {
+		power := (m.quarters[i-3] + m.quarters[i-2] + m.quarters[i-1] + m.quarters[i]) / 4
+		if blockLoudness(power) > -70 {
+			blocks = append(blocks, power)
+		}
+	}
+	if len(blocks) == 0 {
+		return math.Inf(-1)
+	}
+
+	// Relative gate: drop the blocks 10 LU below the loudness of the rest
+	gate := blockLoudness(mean(blocks)) - 10
+	var gated []float64
+	for _, power := range blocks {
+		if blockLoudness(power) > gate {
+			gated = append(gated, power)
+		}
+	}
+	return blockLoudness(mean(gated))
+}
+
+// blockLoudness converts the mean power of both channels to LUFS
+func blockLoudness(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// loudnessGain returns the volume change, in the base 2 steps of
+// effects.Volume, that brings a sound of the given loudness and peak to
+// LoudnessTarget without clipping; 0 loudness is a sound too quiet to
+// measure
+func loudnessGain(loudness, peak float64) float64 {
+	if loudness == 0 || peak == 0 {
+		return 0
+	}
+	gain := (LoudnessTarget - loudness) / 20 // in powers of 10
+	gain = math.Min(gain, math.Log10(maxPeak/peak))
+	return gain * math.Log2(10)
+}
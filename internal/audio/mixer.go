@@ -0,0 +1,261 @@
+package audio
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+)
+
+// ChannelVolumeLevels are the volume steps offered for each sound in the
+// Sounds submenu, relative to the master volume
+var ChannelVolumeLevels = []struct {
+	Name   string
+	Volume float64
+}{
+	{"Quiet", -3},
+	{"Low", -2},
+	{"Medium", -1},
+	{"Full", 0},
+}
+
+// Channel is one sound layer in the mix, e.g. rain playing alongside a
+// fireplace. Each channel loops on its own and is routed through the
+// player's beep.Mixer.
+type Channel struct {
+	path      string
+	streamer  beep.StreamSeekCloser
+	generated beep.Streamer // endless source played instead of looping a file
+	format    beep.Format
+	meta      SoundMeta
+	gain      float64 // loudness normalization, added to the channel volume
+
+	// ctrl is what the mixer plays; clearing its Streamer drops the
+	// channel from the mix
+	ctrl      *beep.Ctrl
+	fx        *filterChain
+	resampler *beep.Resampler // to the speaker's rate, and the sound's speed
+	volume    *effects.Volume
+	fader     *Fader         // fades the channel in and out as the mix changes
+	loop      *CrossfadeLoop // nil when the sound loops without crossfade
+}
+
+// openChannel loads a sound file, or a folder of clips as a generative
+// soundscape, ready to be added to the mix
+func (sp *Player) openChannel(path string) (*Channel, error) {
+	c := &Channel{path: path}
+
+	// Built-in noise is synthesized, there is no file to read
+	if isNoiseSound(path) {
+		noise, err := newNoise(strings.TrimPrefix(path, noisePrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		c.generated = noise
+		c.format = noiseFormat
+		return c, nil
+	}
+	if isToneSound(path) {
+		tone, err := newTone(strings.TrimPrefix(path, tonePrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		c.generated = tone
+		c.format = noiseFormat
+		return c, nil
+	}
+
+	// A stream is decoded as it arrives, at a fixed rate
+	if IsStream(path) {
+		stream, err := findStream(path)
+		if err != nil {
+			return nil, err
+		}
+
+		c.generated = newRadio(stream.URL)
+		c.format = noiseFormat
+		return c, nil
+	}
+
+	// A folder of short clips plays as a generative soundscape
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		generator, err := newGenerator(path)
+		if err != nil {
+			return nil, err
+		}
+
+		c.generated = generator
+		c.format = generator.format
+		return c, nil
+	}
+
+	streamer, format, err := sp.openSound(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.format = format
+	c.meta = loadSoundMeta(path)
+
+	// Once a file has been measured, it loses its leading and trailing
+	// silence unless it has a loop region or trim offsets of its own, and
+	// is brought to the loudness of the others
+	if sp.Analysis != nil {
+		if a, ok := sp.Analysis.lookup(path); ok {
+			if sp.TrimSilence && c.meta.TrimStart == 0 && c.meta.TrimEnd == 0 && !c.meta.hasLoopRegion() {
+				c.meta.TrimStart, c.meta.TrimEnd = a.Start, a.End
+			}
+			if sp.Normalize {
+				c.gain = loudnessGain(a.Loudness, a.Peak)
+			}
+		}
+	}
+
+	// Stretched sounds are read into memory once and played as grains
+	if c.meta.Stretch > 1 {
+		trimmed := c.meta.trim(streamer, format.SampleRate)
+		c.generated = newStretcher(readSamples(trimmed), format.SampleRate, c.meta.Stretch)
+		streamer.Close()
+		return c, nil
+	}
+
+	// Short loops are kept in memory, long files are streamed
+	c.streamer, err = sp.preload(streamer, format)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// IsSynthesized reports whether a sound is built in and generated while
+// playing rather than read from a file
+func IsSynthesized(path string) bool {
+	return isNoiseSound(path) || isToneSound(path)
+}
+
+// stream returns the endless stream of the channel at the given volume
+// and with its effects, resampled to the speaker's sample rate and the
+// sound's speed
+func (c *Channel) stream(sampleRate beep.SampleRate, volume float64, fx SoundEffects, crossfade time.Duration) beep.Streamer {
+	var s beep.Streamer
+	c.loop = nil
+	if c.generated != nil {
+		// Generated sources never end, so there is nothing to loop
+		s = c.generated
+	} else {
+		trimmed := c.meta.trim(c.streamer, c.format.SampleRate)
+		if overlap := min(c.format.SampleRate.N(crossfade), trimmed.Len()/2); overlap > 0 {
+			// Loop the trimmed part of the sound, fading its end into
+			// its start
+			c.loop = newCrossfadeLoop(trimmed, overlap)
+			s = c.loop
+		} else {
+			// Create a looping streamer over the trimmed part of the
+			// sound, starting from its beginning; a sound too short to
+			// overlap itself loops this way too
+			s = beep.Loop(-1, trimmed)
+		}
+	}
+
+	c.resampler = beep.ResampleRatio(4, c.ratio(sampleRate, fx.Speed), s)
+	c.fx = newFilterChain(c.resampler, sampleRate, fx)
+	s = c.fx
+
+	c.volume = &effects.Volume{
+		Streamer: s,
+		Base:     2,
+		Volume:   volume + c.gain,
+	}
+	c.fader = newFader(c.volume, 1)
+	c.ctrl = &beep.Ctrl{Streamer: c.fader}
+	return c.ctrl
+}
+
+// ratio returns how many samples of the sound make one at the speaker's
+// sample rate, played at speed
+func (c *Channel) ratio(sampleRate beep.SampleRate, speed float64) float64 {
+	return float64(c.format.SampleRate) / float64(sampleRate) * (1 + speed)
+}
+
+// position returns where the channel is in its sound, or -1 for
+// generated sources, which have no position to keep
+func (c *Channel) position() int {
+	switch {
+	case c.loop != nil:
+		return c.loop.Position()
+	case c.streamer != nil:
+		return c.streamer.Position()
+	}
+	return -1
+}
+
+// seek moves the channel to a position returned by position
+func (c *Channel) seek(p int) {
+	switch {
+	case c.loop != nil:
+		c.loop.Seek(p)
+	case c.streamer != nil:
+		c.streamer.Seek(p)
+	}
+}
+
+// close releases the sound file; the channel must be out of the mix
+func (c *Channel) close() {
+	if c.streamer != nil {
+		c.streamer.Close()
+	}
+	if closer, ok := c.generated.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// ChannelVolume returns the volume of a sound relative to the master volume
+func (sp *Player) ChannelVolume(path string) float64 {
+	return sp.perChannel[path]
+}
+
+// SetChannelVolume changes the volume of one sound in the mix while it
+// keeps playing
+func (sp *Player) SetChannelVolume(path string, vol float64) {
+	sp.perChannel[path] = vol
+
+	if c := sp.Channel(path); c != nil && c.volume != nil {
+		sp.out.Lock()
+		c.volume.Volume = vol + c.gain
+		sp.out.Unlock()
+	}
+}
+
+// Analysed receives the sound files whose analysis has just finished,
+// while they may be playing already
+func (sp *Player) Analysed() <-chan string {
+	if sp.Analysis == nil {
+		return nil
+	}
+	return sp.Analysis.done
+}
+
+// ApplyAnalysis brings a sound in the mix that has just been measured to
+// the loudness of the others. The silence is only trimmed from its next
+// play on, cutting the loop short now would be audible.
+func (sp *Player) ApplyAnalysis(path string) {
+	c := sp.Channel(path)
+	if c == nil || !sp.Normalize {
+		return
+	}
+	a, ok := sp.Analysis.lookup(path)
+	if !ok {
+		return
+	}
+	c.gain = loudnessGain(a.Loudness, a.Peak)
+	if c.volume != nil {
+		sp.out.Lock()
+		c.volume.Volume = sp.ChannelVolume(path) + c.gain
+		sp.out.Unlock()
+	}
+}
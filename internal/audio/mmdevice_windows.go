@@ -0,0 +1,171 @@
+package audio
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"rogverse.fyi/ambiantgo/internal/winapi"
+)
+
+var (
+	procCoCreateInstance = winapi.Ole32.NewProc("CoCreateInstance")
+	procCoTaskMemFree    = winapi.Ole32.NewProc("CoTaskMemFree")
+	procPropVariantClear = winapi.Ole32.NewProc("PropVariantClear")
+
+	clsidMMDeviceEnumerator = syscall.GUID{Data1: 0xBCDE0395, Data2: 0xE52F, Data3: 0x467C, Data4: [8]byte{0x8E, 0x3D, 0xC4, 0x57, 0x92, 0x91, 0x69, 0x2E}}
+	iidIMMDeviceEnumerator  = syscall.GUID{Data1: 0xA95664D2, Data2: 0x9614, Data3: 0x4F35, Data4: [8]byte{0xA7, 0x46, 0xDE, 0x8D, 0xB6, 0x36, 0x17, 0xE6}}
+	iidIAudioSessionManager = syscall.GUID{Data1: 0xBFA971F1, Data2: 0x4D5E, Data3: 0x40BB, Data4: [8]byte{0x93, 0x5E, 0x96, 0x70, 0x39, 0xBF, 0xBE, 0xE4}}
+
+	pkeyAudioEndpointFormFactor = propertyKey{
+		fmtid: syscall.GUID{Data1: 0x1DA5D803, Data2: 0xD492, Data3: 0x4EDD, Data4: [8]byte{0x8C, 0x23, 0xE0, 0xC0, 0xFF, 0xEE, 0x7F, 0x0E}},
+		pid:   0,
+	}
+)
+
+// propertyKey mirrors the Win32 PROPERTYKEY struct
+type propertyKey struct {
+	fmtid syscall.GUID
+	pid   uint32
+}
+
+// propVariant mirrors PROPVARIANT, sized for 64-bit builds
+type propVariant struct {
+	vt       uint16
+	reserved [3]uint16
+	val      [2]uint64
+}
+
+const (
+	clsctxAll         = 0x17
+	eRender           = 0
+	eConsole          = 0
+	deviceStateActive = 0x1
+	stgmRead          = 0x0
+	vtUI4             = 19
+
+	// EndpointFormFactor values for headphone-type outputs
+	formFactorHeadphones = 3
+	formFactorHeadset    = 5
+
+	// vtable slots; 0-2 are the IUnknown methods
+	immDeviceEnumeratorGetDefault      = 4
+	immDeviceEnumeratorGetDevice       = 5
+	immDeviceActivate                  = 3
+	immDeviceOpenPropertyStore         = 4
+	immDeviceGetID                     = 5
+	immDeviceGetState                  = 6
+	ipropertyStoreGetValue             = 5
+	iaudioSessionManagerGetSessionCtrl = 3
+	iaudioSessionControlSetDisplayName = 5
+	iaudioSessionControlSetIconPath    = 7
+)
+
+// withDeviceEnumerator runs fn with a device enumerator on a COM thread
+func withDeviceEnumerator(fn func(enumerator unsafe.Pointer) error) error {
+	done := make(chan error)
+	go func() {
+		// COM calls must stay on the thread that initialized COM
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		uninit, err := winapi.ComInit()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer uninit()
+
+		enumerator, err := newDeviceEnumerator()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer winapi.ComRelease(enumerator)
+
+		done <- fn(enumerator)
+	}()
+	return <-done
+}
+
+func newDeviceEnumerator() (unsafe.Pointer, error) {
+	var enumerator unsafe.Pointer
+	hr, _, _ := procCoCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidMMDeviceEnumerator)), 0, clsctxAll,
+		uintptr(unsafe.Pointer(&iidIMMDeviceEnumerator)), uintptr(unsafe.Pointer(&enumerator)))
+	if int32(hr) < 0 {
+		return nil, fmt.Errorf("creating device enumerator failed: 0x%08x", uint32(hr))
+	}
+	return enumerator, nil
+}
+
+// defaultOutputDevice returns the IMMDevice of the default output endpoint
+func defaultOutputDevice(enumerator unsafe.Pointer) (unsafe.Pointer, error) {
+	var device unsafe.Pointer
+	if hr := winapi.ComCall(enumerator, immDeviceEnumeratorGetDefault, eRender, eConsole, uintptr(unsafe.Pointer(&device))); int32(hr) < 0 {
+		return nil, fmt.Errorf("getting default output device failed: 0x%08x", uint32(hr))
+	}
+	return device, nil
+}
+
+// deviceByID returns the IMMDevice for an endpoint ID
+func deviceByID(enumerator unsafe.Pointer, id string) (unsafe.Pointer, error) {
+	idPtr, err := syscall.UTF16PtrFromString(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var device unsafe.Pointer
+	if hr := winapi.ComCall(enumerator, immDeviceEnumeratorGetDevice, uintptr(unsafe.Pointer(idPtr)), uintptr(unsafe.Pointer(&device))); int32(hr) < 0 {
+		return nil, fmt.Errorf("getting device failed: 0x%08x", uint32(hr))
+	}
+	return device, nil
+}
+
+// deviceState returns the DEVICE_STATE_* flags of an IMMDevice
+func deviceState(device unsafe.Pointer) (uint32, error) {
+	var state uint32
+	if hr := winapi.ComCall(device, immDeviceGetState, uintptr(unsafe.Pointer(&state))); int32(hr) < 0 {
+		return 0, fmt.Errorf("getting device state failed: 0x%08x", uint32(hr))
+	}
+	return state, nil
+}
+
+// deviceFormFactor returns the EndpointFormFactor of an IMMDevice
+func deviceFormFactor(device unsafe.Pointer) (uint32, error) {
+	var store unsafe.Pointer
+	if hr := winapi.ComCall(device, immDeviceOpenPropertyStore, stgmRead, uintptr(unsafe.Pointer(&store))); int32(hr) < 0 {
+		return 0, fmt.Errorf("opening device properties failed: 0x%08x", uint32(hr))
+	}
+	defer winapi.ComRelease(store)
+
+	var value propVariant
+	if hr := winapi.ComCall(store, ipropertyStoreGetValue, uintptr(unsafe.Pointer(&pkeyAudioEndpointFormFactor)), uintptr(unsafe.Pointer(&value))); int32(hr) < 0 {
+		return 0, fmt.Errorf("reading device form factor failed: 0x%08x", uint32(hr))
+	}
+	defer procPropVariantClear.Call(uintptr(unsafe.Pointer(&value)))
+
+	if value.vt != vtUI4 {
+		return 0, fmt.Errorf("unexpected form factor type %d", value.vt)
+	}
+	return uint32(value.val[0]), nil
+}
+
+// deviceID returns the endpoint ID string of an IMMDevice
+func deviceID(device unsafe.Pointer) (string, error) {
+	var id *uint16
+	if hr := winapi.ComCall(device, immDeviceGetID, uintptr(unsafe.Pointer(&id))); int32(hr) < 0 {
+		return "", fmt.Errorf("getting device id failed: 0x%08x", uint32(hr))
+	}
+	defer procCoTaskMemFree.Call(uintptr(unsafe.Pointer(id)))
+	return syscall.UTF16ToString(unsafe.Slice(id, utf16Len(id))), nil
+}
+
+func utf16Len(p *uint16) int {
+	n := 0
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; n++ {
+		ptr = unsafe.Add(ptr, 2)
+	}
+	return n
+}
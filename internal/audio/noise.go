@@ -0,0 +1,103 @@
+package audio
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/faiface/beep"
+)
+
+// noisePrefix marks the built-in noise sounds in the sound list, which
+// are synthesized instead of read from a file
+const noisePrefix = "noise:"
+
+// noiseKinds are the colors of noise offered in the Sounds menu
+var noiseKinds = []string{"white", "pink", "brown", "grey"}
+
+// noiseFormat is the format noise is generated in
+var noiseFormat = beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 2}
+
+// noiseSounds returns the sound list entries of the built-in noises
+func noiseSounds() []string {
+	var sounds []string
+	for _, kind := range noiseKinds {
+		sounds = append(sounds, noisePrefix+kind)
+	}
+	return sounds
+}
+
+// isNoiseSound reports whether path names a built-in noise
+func isNoiseSound(path string) bool {
+	return strings.HasPrefix(path, noisePrefix)
+}
+
+// Noise is an endless stream of colored noise. Each side of the stereo
+// image is generated separately so the noise sounds wide.
+type Noise struct {
+	kind  string
+	rng   *rand.Rand
+	pink  [2][7]float64 // filter state per side
+	brown [2]float64
+}
+
+// newNoise returns a generator for one of noiseKinds
+func newNoise(kind string) (*Noise, error) {
+	found := false
+	for _, k := range noiseKinds {
+		found = found || k == kind
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown noise %q", kind)
+	}
+	return &Noise{kind: kind, rng: rand.New(rand.NewSource(rand.Int63()))}, nil
+}
+
+// Stream never ends
+func (n *Noise) Stream(samples [][2]float64) (int, bool) {
+	for i := range samples {
+		for c := 0; c < 2; c++ {
+			white := n.rng.Float64()*2 - 1
+
+			switch n.kind {
+			case "white":
+				samples[i][c] = white * 0.25
+			case "pink":
+				samples[i][c] = n.pinkSample(c, white) * 0.75
+			case "brown":
+				samples[i][c] = n.brownSample(c, white) * 0.7
+			case "grey":
+				// Rough inverse of the ear's loudness curve: more lows
+				// and highs than the middle, so it sounds evenly loud
+				samples[i][c] = n.brownSample(c, white)*0.5 + white*0.17
+			}
+		}
+	}
+	return len(samples), true
+}
+
+func (n *Noise) Err() error {
+	return nil
+}
+
+// pinkSample filters white noise down by 3dB per octave, using Paul
+// Kellet's refined filter
+func (n *Noise) pinkSample(c int, white float64) float64 {
+	b := &n.pink[c]
+	b[0] = 0.99886*b[0] + white*0.0555179
+	b[1] = 0.99332*b[1] + white*0.0750759
+	b[2] = 0.96900*b[2] + white*0.1538520
+	b[3] = 0.86650*b[3] + white*0.3104856
+	b[4] = 0.55000*b[4] + white*0.5329522
+	b[5] = -0.7616*b[5] - white*0.0168980
+	pink := b[0] + b[1] + b[2] + b[3] + b[4] + b[5] + b[6] + white*0.5362
+	b[6] = white * 0.115926
+	return pink * 0.11
+}
+
+// brownSample integrates white noise with a slight leak, 6dB per octave
+// down, so it never drifts off
+func (n *Noise) brownSample(c int, white float64) float64 {
+	n.brown[c] = (n.brown[c] + 0.02*white) / 1.02
+	return n.brown[c] * 3.5
+}
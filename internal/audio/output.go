@@ -0,0 +1,43 @@
+package audio
+
+import (
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// Backend is the device the mix is played on, shaped after beep's speaker
+// package. The default one is the speaker itself, which always plays on
+// the system default device; FakeBackend plays nowhere, for tests.
+type Backend interface {
+	// Init opens the device, closing it first if it was open
+	Init(sampleRate beep.SampleRate, bufferSize int) error
+	Play(s ...beep.Streamer)
+	Clear()
+	// Lock stops the device pulling samples until Unlock, so playing
+	// streamers can be changed safely
+	Lock()
+	Unlock()
+	Close()
+}
+
+// defaultSpeaker plays through beep's speaker on the default device
+type defaultSpeaker struct{}
+
+func (defaultSpeaker) Init(sampleRate beep.SampleRate, bufferSize int) error {
+	return speaker.Init(sampleRate, bufferSize)
+}
+
+func (defaultSpeaker) Play(s ...beep.Streamer) { speaker.Play(s...) }
+func (defaultSpeaker) Clear()                  { speaker.Clear() }
+func (defaultSpeaker) Lock()                   { speaker.Lock() }
+func (defaultSpeaker) Unlock()                 { speaker.Unlock() }
+func (defaultSpeaker) Close()                  { speaker.Close() }
+
+// newBackend returns the backend for a device from ListOutputDevices,
+// or the default speaker for an empty name
+func newBackend(device string) Backend {
+	if device == "" {
+		return defaultSpeaker{}
+	}
+	return newDeviceOutput(device)
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package audio
+
+// ListOutputDevices returns nil; only the default device can be used here
+func ListOutputDevices() []string {
+	return nil
+}
+
+// newDeviceOutput falls back to the default speaker
+func newDeviceOutput(device string) Backend {
+	logger.Warn("Output device selection isn't supported here, using the default device", "device", device)
+	return defaultSpeaker{}
+}
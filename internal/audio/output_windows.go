@@ -0,0 +1,229 @@
+package audio
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/faiface/beep"
+
+	"rogverse.fyi/ambiantgo/internal/winapi"
+)
+
+var (
+	winmm                      = syscall.NewLazyDLL("winmm.dll")
+	procWaveOutGetNumDevs      = winmm.NewProc("waveOutGetNumDevs")
+	procWaveOutGetDevCaps      = winmm.NewProc("waveOutGetDevCapsW")
+	procWaveOutOpen            = winmm.NewProc("waveOutOpen")
+	procWaveOutPrepareHeader   = winmm.NewProc("waveOutPrepareHeader")
+	procWaveOutUnprepareHeader = winmm.NewProc("waveOutUnprepareHeader")
+	procWaveOutWrite           = winmm.NewProc("waveOutWrite")
+	procWaveOutReset           = winmm.NewProc("waveOutReset")
+	procWaveOutClose           = winmm.NewProc("waveOutClose")
+
+	procCreateEvent         = winapi.Kernel32.NewProc("CreateEventW")
+	procWaitForSingleObject = winapi.Kernel32.NewProc("WaitForSingleObject")
+	procCloseHandle         = winapi.Kernel32.NewProc("CloseHandle")
+)
+
+const (
+	waveMapper    = 0xFFFFFFFF
+	waveFormatPCM = 1
+	callbackEvent = 0x50000
+	whdrDone      = 0x1
+	waveBuffers   = 4
+)
+
+type waveOutCaps struct {
+	mid, pid      uint16
+	driverVersion uint32
+	name          [32]uint16
+	formats       uint32
+	channels      uint16
+	reserved      uint16
+	support       uint32
+}
+
+type waveFormatEx struct {
+	formatTag      uint16
+	channels       uint16
+	samplesPerSec  uint32
+	avgBytesPerSec uint32
+	blockAlign     uint16
+	bitsPerSample  uint16
+	size           uint16
+}
+
+type waveHdr struct {
+	data          uintptr
+	bufferLength  uint32
+	bytesRecorded uint32
+	user          uintptr
+	flags         uint32
+	loops         uint32
+	next          uintptr
+	reserved      uintptr
+}
+
+// ListOutputDevices returns the names of the audio outputs, as waveOut
+// knows them (at most 31 characters)
+func ListOutputDevices() []string {
+	var names []string
+	n, _, _ := procWaveOutGetNumDevs.Call()
+	for i := uintptr(0); i < n; i++ {
+		var caps waveOutCaps
+		if r, _, _ := procWaveOutGetDevCaps.Call(i, uintptr(unsafe.Pointer(&caps)), unsafe.Sizeof(caps)); r != 0 {
+			continue
+		}
+		names = append(names, syscall.UTF16ToString(caps.name[:]))
+	}
+	return names
+}
+
+// waveOutput plays the mix on a chosen device through waveOut, feeding it
+// from a mixer the same way beep's speaker does
+type waveOutput struct {
+	device string
+
+	mu      sync.Mutex
+	mixer   beep.Mixer
+	handle  uintptr
+	event   uintptr
+	headers []*waveHdr
+	buffers [][]byte
+	samples [][2]float64
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newDeviceOutput(device string) Backend {
+	return &waveOutput{device: device}
+}
+
+// deviceIndex finds the waveOut device by name, or the default device if
+// it's gone
+func (w *waveOutput) deviceIndex() uintptr {
+	for i, name := range ListOutputDevices() {
+		if name == w.device {
+			return uintptr(i)
+		}
+	}
+	logger.Warn("Output device not found, using the default device", "device", w.device)
+	return waveMapper
+}
+
+func (w *waveOutput) Init(sampleRate beep.SampleRate, bufferSize int) error {
+	w.Close()
+
+	format := waveFormatEx{
+		formatTag:     waveFormatPCM,
+		channels:      2,
+		samplesPerSec: uint32(sampleRate),
+		bitsPerSample: 16,
+		blockAlign:    4,
+	}
+	format.avgBytesPerSec = format.samplesPerSec * uint32(format.blockAlign)
+
+	event, _, err := procCreateEvent.Call(0, 0, 0, 0)
+	if event == 0 {
+		return fmt.Errorf("CreateEvent failed: %v", err)
+	}
+	var handle uintptr
+	if r, _, _ := procWaveOutOpen.Call(uintptr(unsafe.Pointer(&handle)), w.deviceIndex(), uintptr(unsafe.Pointer(&format)), event, 0, callbackEvent); r != 0 {
+		procCloseHandle.Call(event)
+		return fmt.Errorf("waveOutOpen failed: %d", r)
+	}
+	w.handle, w.event = handle, event
+
+	// The buffer is split in a few parts so one plays while the next fills
+	frames := max(bufferSize/waveBuffers, 256)
+	w.samples = make([][2]float64, frames)
+	w.headers = nil
+	w.buffers = nil
+	for i := 0; i < waveBuffers; i++ {
+		buf := make([]byte, frames*4)
+		hdr := &waveHdr{data: uintptr(unsafe.Pointer(&buf[0])), bufferLength: uint32(len(buf)), flags: whdrDone}
+		procWaveOutPrepareHeader.Call(handle, uintptr(unsafe.Pointer(hdr)), unsafe.Sizeof(*hdr))
+		w.buffers = append(w.buffers, buf)
+		w.headers = append(w.headers, hdr)
+	}
+
+	w.done = make(chan struct{})
+	w.stopped = make(chan struct{})
+	go w.run()
+	return nil
+}
+
+// run refills and queues each buffer as soon as it has been played
+func (w *waveOutput) run() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		for i, hdr := range w.headers {
+			if hdr.flags&whdrDone == 0 {
+				continue
+			}
+			w.fill(w.buffers[i])
+			hdr.flags &^= whdrDone
+			procWaveOutWrite.Call(w.handle, uintptr(unsafe.Pointer(hdr)), unsafe.Sizeof(*hdr))
+		}
+		procWaitForSingleObject.Call(w.event, 100)
+	}
+}
+
+// fill renders the next part of the mix into buf as 16-bit PCM
+func (w *waveOutput) fill(buf []byte) {
+	w.mu.Lock()
+	w.mixer.Stream(w.samples)
+	w.mu.Unlock()
+
+	for i, sample := range w.samples {
+		for c, val := range sample {
+			val = min(max(val, -1), 1)
+			v := int16(val * (1<<15 - 1))
+			buf[i*4+c*2] = byte(v)
+			buf[i*4+c*2+1] = byte(v >> 8)
+		}
+	}
+}
+
+func (w *waveOutput) Play(s ...beep.Streamer) {
+	w.mu.Lock()
+	w.mixer.Add(s...)
+	w.mu.Unlock()
+}
+
+func (w *waveOutput) Clear() {
+	w.mu.Lock()
+	w.mixer.Clear()
+	w.mu.Unlock()
+}
+
+func (w *waveOutput) Lock()   { w.mu.Lock() }
+func (w *waveOutput) Unlock() { w.mu.Unlock() }
+
+func (w *waveOutput) Close() {
+	if w.handle == 0 {
+		return
+	}
+	close(w.done)
+	<-w.stopped
+
+	procWaveOutReset.Call(w.handle)
+	for _, hdr := range w.headers {
+		procWaveOutUnprepareHeader.Call(w.handle, uintptr(unsafe.Pointer(hdr)), unsafe.Sizeof(*hdr))
+	}
+	procWaveOutClose.Call(w.handle)
+	procCloseHandle.Call(w.event)
+	w.handle = 0
+
+	w.mu.Lock()
+	w.mixer.Clear()
+	w.mu.Unlock()
+}
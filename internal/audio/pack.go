@@ -0,0 +1,225 @@
+package audio
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackExt is the extension of sound packs: a zip of audio files with a
+// manifest.json describing them
+const PackExt = ".ambipack"
+
+// packManifestName is the manifest's name inside a pack
+const packManifestName = "manifest.json"
+
+// PackManifest describes a sound pack, a soundscape shared as one file.
+// Its sounds make up a mix that is saved as a preset when imported.
+type PackManifest struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Author      string      `json:"author,omitempty"`
+	Sounds      []PackSound `json:"sounds"`
+}
+
+// PackSound is one sound of a pack. The trim offsets and loop markers
+// are the suggested loop region; the credits end up in the sound's
+// metadata file.
+type PackSound struct {
+	File      string    `json:"file"` // name of the audio file in the zip
+	Name      string    `json:"name,omitempty"`
+	Category  string    `json:"category,omitempty"`
+	Volume    float64   `json:"volume,omitempty"` // relative to the master volume
+	TrimStart float64   `json:"trim_start,omitempty"`
+	TrimEnd   float64   `json:"trim_end,omitempty"`
+	LoopStart Timestamp `json:"loop_start,omitempty"`
+	LoopEnd   Timestamp `json:"loop_end,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	License   string    `json:"license,omitempty"`
+	Source    string    `json:"source,omitempty"`
+}
+
+// ExportPack writes the sounds of a preset to a pack at path, with their
+// metadata and volumes. Built-in sounds and streams have no file and are
+// left out, as are soundscape folders.
+func ExportPack(path string, p Preset) error {
+	manifest := PackManifest{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	for _, sound := range p.Sounds {
+		if info, err := os.Stat(sound); IsSynthesized(sound) || IsStream(sound) || err == nil && info.IsDir() {
+			continue
+		}
+		meta := loadSoundMeta(sound)
+		manifest.Sounds = append(manifest.Sounds, PackSound{
+			File:      SoundFile(sound),
+			Name:      SoundName(sound),
+			Category:  meta.Category,
+			Volume:    p.Volumes[sound],
+			TrimStart: meta.TrimStart,
+			TrimEnd:   meta.TrimEnd,
+			LoopStart: meta.LoopStart,
+			LoopEnd:   meta.LoopEnd,
+			Author:    meta.Author,
+			License:   meta.License,
+			Source:    meta.Source,
+		})
+	}
+	if len(manifest.Sounds) == 0 {
+		return fmt.Errorf("the mix has no sound files to export")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = writePack(f, manifest, p.Sounds)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+func writePack(w io.Writer, manifest PackManifest, sounds []string) error {
+	z := zip.NewWriter(w)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	mw, err := z.Create(packManifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write(data); err != nil {
+		return err
+	}
+
+	for _, s := range manifest.Sounds {
+		for _, sound := range sounds {
+			if SoundFile(sound) != s.File {
+				continue
+			}
+			// Audio is compressed already
+			fw, err := z.CreateHeader(&zip.FileHeader{Name: s.File, Method: zip.Store})
+			if err != nil {
+				return err
+			}
+			in, err := openSoundFile(sound)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(fw, in)
+			in.Close()
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return z.Close()
+}
+
+// ImportPack unpacks a pack into soundsDir and returns its mix as a
+// preset named after the pack, without a master volume. Each sound is
+// saved under its name in the manifest, with its loop region, category
+// and credits in its metadata file; a sound already in the folder under
+// that name is kept and used.
+func ImportPack(path, soundsDir string) (Preset, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return Preset{}, err
+	}
+	defer z.Close()
+
+	var manifest PackManifest
+	mf, err := z.Open(packManifestName)
+	if err != nil {
+		return Preset{}, fmt.Errorf("not a sound pack: %w", err)
+	}
+	err = json.NewDecoder(mf).Decode(&manifest)
+	mf.Close()
+	if err != nil {
+		return Preset{}, fmt.Errorf("parsing %s: %w", packManifestName, err)
+	}
+	if manifest.Name == "" {
+		manifest.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	p := Preset{Name: manifest.Name, Volumes: make(map[string]float64)}
+	for _, s := range manifest.Sounds {
+		dest, err := importPackSound(z, s, soundsDir)
+		if err != nil {
+			return Preset{}, fmt.Errorf("importing %s: %w", s.File, err)
+		}
+		p.Sounds = append(p.Sounds, dest)
+		if s.Volume != 0 {
+			p.Volumes[dest] = s.Volume
+		}
+	}
+	if len(p.Sounds) == 0 {
+		return Preset{}, fmt.Errorf("the pack has no sounds")
+	}
+	return p, nil
+}
+
+// importPackSound extracts one sound of a pack and writes its metadata
+func importPackSound(z *zip.ReadCloser, s PackSound, soundsDir string) (string, error) {
+	file := filepath.Base(s.File)
+	if !isSupportedSound(file) || file != s.File {
+		return "", fmt.Errorf("invalid file name")
+	}
+	name := file
+	if s.Name != "" && !strings.ContainsAny(s.Name, `<>:"/\|?*`) {
+		name = s.Name + filepath.Ext(file)
+	}
+	dest := filepath.Join(soundsDir, name)
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+
+	// Metadata first, so the sound is never listed without it
+	meta, err := json.MarshalIndent(SoundMeta{
+		TrimStart: s.TrimStart,
+		TrimEnd:   s.TrimEnd,
+		LoopStart: s.LoopStart,
+		LoopEnd:   s.LoopEnd,
+		Category:  s.Category,
+		Author:    s.Author,
+		License:   s.License,
+		Source:    s.Source,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(dest+".json", meta, 0o644); err != nil {
+		return "", err
+	}
+
+	in, err := z.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	part := dest + ".part"
+	out, err := os.Create(part)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(part, dest)
+	}
+	if err != nil {
+		os.Remove(part)
+		return "", err
+	}
+	return dest, nil
+}
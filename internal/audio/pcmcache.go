@@ -0,0 +1,344 @@
+package audio
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// pcmMagic starts every cache file, followed by the sample rate. It
+// changes when decoding does, e.g. WAV files playing at full level, so
+// older files are decoded again.
+const pcmMagic = "AGPCM2\x00\x00"
+
+const pcmHeaderSize = len(pcmMagic) + 4
+
+// pcmIndex is the file in the cache folder that maps sound files to the
+// hash of their contents
+const pcmIndex = "index.json"
+
+// PCMCache keeps decoded sounds on disk as 16-bit PCM, keyed by a hash of
+// the file contents, so loading a sound again skips decoding and playback
+// can start right away. Files are only hashed when they are stored; the
+// hashes are kept in an index, so a hit only needs a stat.
+type PCMCache struct {
+	dir      string
+	maxBytes int64
+
+	mu     sync.Mutex
+	hashes map[string]fileHash
+}
+
+// fileHash is the hash of one version of a file
+type fileHash struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Sum     string    `json:"sum"`
+}
+
+func NewPCMCache(dir string, maxBytes int64) *PCMCache {
+	c := &PCMCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		hashes:   make(map[string]fileHash),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, pcmIndex))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Reading the sound cache index failed", "err", err)
+		}
+		return c
+	}
+	if err := json.Unmarshal(data, &c.hashes); err != nil {
+		logger.Error("Parsing the sound cache index failed", "err", err)
+	}
+	return c
+}
+
+// open returns the cached PCM of a sound, if it has been cached before
+func (c *PCMCache) open(filename string) (beep.StreamSeekCloser, beep.Format, bool) {
+	sum, ok := c.lookup(filename)
+	if !ok {
+		return nil, beep.Format{}, false
+	}
+
+	streamer, format, err := openPCM(c.path(sum))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Reading a cached sound failed", "err", err)
+			// Make way for a fresh copy
+			os.Remove(c.path(sum))
+		}
+		return nil, beep.Format{}, false
+	}
+
+	// Keep recently used entries from being pruned
+	now := time.Now()
+	os.Chtimes(c.path(sum), now, now)
+	return streamer, format, true
+}
+
+// store decodes a sound into the cache in the background
+func (c *PCMCache) store(filename string) {
+	go func() {
+		if err := c.write(filename); err != nil {
+			logger.Error("Caching a sound failed", "err", err)
+			return
+		}
+		c.prune()
+	}()
+}
+
+func (c *PCMCache) write(filename string) error {
+	sum, err := c.hash(filename)
+	if err != nil {
+		return err
+	}
+	dest := c.path(sum)
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+
+	streamer, format, err := openAudio(filename)
+	if err != nil {
+		return err
+	}
+	defer streamer.Close()
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	header := make([]byte, pcmHeaderSize)
+	copy(header, pcmMagic)
+	binary.LittleEndian.PutUint32(header[len(pcmMagic):], uint32(format.SampleRate))
+	w.Write(header)
+
+	pcmFormat := beep.Format{SampleRate: format.SampleRate, NumChannels: 2, Precision: 2}
+	samples := make([][2]float64, 4096)
+	frame := make([]byte, 4)
+	for {
+		n, ok := streamer.Stream(samples)
+		for _, sample := range samples[:n] {
+			pcmFormat.EncodeSigned(frame, sample)
+			w.Write(frame)
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// prune deletes the least recently used entries above the size limit
+func (c *PCMCache) prune() {
+	matches, err := filepath.Glob(filepath.Join(c.dir, "*.pcm"))
+	if err != nil {
+		return
+	}
+
+	var infos []os.FileInfo
+	var total int64
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+		total += info.Size()
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err == nil {
+			total -= info.Size()
+		}
+	}
+}
+
+func (c *PCMCache) path(sum string) string {
+	return filepath.Join(c.dir, sum+".pcm")
+}
+
+// lookup returns the remembered hash of a file, if it hasn't changed on
+// disk since it was stored
+func (c *PCMCache) lookup(filename string) (string, bool) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.hashes[filename]
+	if !ok || cached.Size != info.Size() || !cached.ModTime.Equal(info.ModTime()) {
+		return "", false
+	}
+	return cached.Sum, true
+}
+
+// hash returns the SHA-256 of a file, remembering it in the index until
+// the file changes on disk
+func (c *PCMCache) hash(filename string) (string, error) {
+	if sum, ok := c.lookup(filename); ok {
+		return sum, nil
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	c.hashes[filename] = fileHash{Size: info.Size(), ModTime: info.ModTime(), Sum: sum}
+	data, err := json.MarshalIndent(c.hashes, "", "  ")
+	c.mu.Unlock()
+
+	if err == nil {
+		os.MkdirAll(c.dir, 0o755)
+		err = os.WriteFile(filepath.Join(c.dir, pcmIndex), data, 0o644)
+	}
+	if err != nil {
+		logger.Error("Saving the sound cache index failed", "err", err)
+	}
+	return sum, nil
+}
+
+// pcmStreamer plays a cache file straight from disk
+type pcmStreamer struct {
+	f      *os.File
+	format beep.Format
+	len    int
+	pos    int
+	buf    []byte
+	err    error
+}
+
+func openPCM(path string) (*pcmStreamer, beep.Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	header := make([]byte, pcmHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:len(pcmMagic)]) != pcmMagic {
+		f.Close()
+		return nil, beep.Format{}, fmt.Errorf("%s is not a sound cache file", path)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, beep.Format{}, err
+	}
+
+	format := beep.Format{
+		SampleRate:  beep.SampleRate(binary.LittleEndian.Uint32(header[len(pcmMagic):])),
+		NumChannels: 2,
+		Precision:   2,
+	}
+	s := &pcmStreamer{
+		f:      f,
+		format: format,
+		len:    int(info.Size()-int64(pcmHeaderSize)) / format.Width(),
+	}
+	return s, format, nil
+}
+
+func (s *pcmStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	if s.err != nil || s.pos >= s.len {
+		return 0, false
+	}
+
+	if remaining := s.len - s.pos; len(samples) > remaining {
+		samples = samples[:remaining]
+	}
+
+	width := s.format.Width()
+	if need := len(samples) * width; len(s.buf) < need {
+		s.buf = make([]byte, need)
+	}
+	buf := s.buf[:len(samples)*width]
+
+	read, err := s.f.ReadAt(buf, int64(pcmHeaderSize)+int64(s.pos*width))
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+
+	for n = 0; (n+1)*width <= read; n++ {
+		samples[n], _ = s.format.DecodeSigned(buf[n*width:])
+	}
+	s.pos += n
+	return n, n > 0
+}
+
+func (s *pcmStreamer) Err() error {
+	return s.err
+}
+
+func (s *pcmStreamer) Len() int {
+	return s.len
+}
+
+func (s *pcmStreamer) Position() int {
+	return s.pos
+}
+
+func (s *pcmStreamer) Seek(p int) error {
+	if p < 0 || p > s.len {
+		return fmt.Errorf("seek position %v out of range [%v, %v]", p, 0, s.len)
+	}
+	s.pos = p
+	return nil
+}
+
+func (s *pcmStreamer) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,69 @@
+package audio
+
+// playbackState is where the player is between loading a sound and
+// hearing it. The event loop owns the player and drives every change;
+// the speaker only moves it from fading to paused once a fade-out has
+// gone silent.
+type playbackState int
+
+const (
+	stateStopped playbackState = iota // nothing has played yet
+	statePlaying
+	stateFading // fading out towards a pause, still audible
+	statePaused
+)
+
+func (s playbackState) String() string {
+	switch s {
+	case statePlaying:
+		return "playing"
+	case stateFading:
+		return "fading"
+	case statePaused:
+		return "paused"
+	}
+	return "stopped"
+}
+
+// playbackState returns the current state; it is safe to call from any
+// goroutine
+func (sp *Player) playbackState() playbackState {
+	sp.stateMu.Lock()
+	defer sp.stateMu.Unlock()
+	return sp.state
+}
+
+// IsPlaying reports whether the player is playing and not on its way to
+// a pause
+func (sp *Player) IsPlaying() bool {
+	return sp.playbackState() == statePlaying
+}
+
+func (sp *Player) setState(s playbackState) {
+	sp.stateMu.Lock()
+	sp.state = s
+	sp.stateMu.Unlock()
+}
+
+// fadedOut is called by the speaker once a pause has faded to silence.
+// A play started meanwhile wins.
+func (sp *Player) fadedOut() {
+	sp.stateMu.Lock()
+	faded := sp.state == stateFading
+	if faded {
+		sp.state = statePaused
+	}
+	sp.stateMu.Unlock()
+	if faded {
+		select {
+		case sp.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Changed receives when the state changes without the event loop doing
+// it, e.g. a pause fading to silence, so the loop can show it
+func (sp *Player) Changed() <-chan struct{} {
+	return sp.changed
+}
@@ -0,0 +1,171 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+// testFade is how long plays and pauses fade in these tests, and
+// fadeSamples comfortably more than that at OutputRate
+const (
+	testFade    = 10 * time.Millisecond
+	fadeSamples = 2048
+)
+
+// newNoisePlayer returns a player with white noise in the mix, playing
+// through a FakeBackend
+func newNoisePlayer(t *testing.T) (*Player, *FakeBackend) {
+	t.Helper()
+	out := &FakeBackend{}
+	sp := NewPlayerWithBackend(t.TempDir(), out)
+	sp.Fade = testFade
+	if err := sp.AddSound(noisePrefix + "white"); err != nil {
+		t.Fatal(err)
+	}
+	return sp, out
+}
+
+func checkState(t *testing.T, sp *Player, want playbackState) {
+	t.Helper()
+	if got := sp.playbackState(); got != want {
+		t.Fatalf("state is %v, want %v", got, want)
+	}
+}
+
+// peak returns the loudest sample
+func peak(samples [][2]float64) float64 {
+	var p float64
+	for _, s := range samples {
+		p = max(p, abs(s[0]), abs(s[1]))
+	}
+	return p
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestPlaybackStartsStopped(t *testing.T) {
+	sp, _ := newNoisePlayer(t)
+	checkState(t, sp, stateStopped)
+
+	// Pausing what never played changes nothing
+	sp.Pause()
+	checkState(t, sp, stateStopped)
+}
+
+func TestPlayWithoutSounds(t *testing.T) {
+	sp := NewPlayerWithBackend(t.TempDir(), &FakeBackend{})
+	if err := sp.Play(); err == nil {
+		t.Fatal("playing an empty mix succeeded")
+	}
+	checkState(t, sp, stateStopped)
+}
+
+func TestPauseFadesOut(t *testing.T) {
+	sp, out := newNoisePlayer(t)
+	if err := sp.Play(); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, sp, statePlaying)
+	out.Pull(fadeSamples)
+
+	sp.Pause()
+	checkState(t, sp, stateFading)
+	if sp.IsPlaying() {
+		t.Error("fading counts as playing")
+	}
+	if peak(out.Pull(16)) == 0 {
+		t.Error("silent right after pausing, want a fade-out")
+	}
+
+	out.Pull(fadeSamples)
+	checkState(t, sp, statePaused)
+	select {
+	case <-sp.Changed():
+	default:
+		t.Error("no change reported once the fade-out went silent")
+	}
+	if p := peak(out.Pull(fadeSamples)); p != 0 {
+		t.Errorf("paused output peaks at %v, want silence", p)
+	}
+	if out.Playing() != 0 {
+		t.Errorf("%d streamers left playing after the pause", out.Playing())
+	}
+}
+
+func TestPlayFadesIn(t *testing.T) {
+	sp, out := newNoisePlayer(t)
+	sp.Play()
+
+	start := peak(out.Pull(16))
+	out.Pull(fadeSamples)
+	full := peak(out.Pull(fadeSamples))
+	if start >= full/10 {
+		t.Errorf("start of play peaks at %v, full volume at %v, want a fade-in", start, full)
+	}
+}
+
+func TestPlayDuringFadeOut(t *testing.T) {
+	sp, out := newNoisePlayer(t)
+	sp.Play()
+	out.Pull(fadeSamples)
+
+	sp.Pause()
+	out.Pull(100)
+	if err := sp.Play(); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, sp, statePlaying)
+
+	// The abandoned fade-out must not pause the new play
+	out.Pull(2 * fadeSamples)
+	checkState(t, sp, statePlaying)
+	select {
+	case <-sp.Changed():
+		t.Error("change reported for an abandoned fade-out")
+	default:
+	}
+	if peak(out.Pull(fadeSamples)) == 0 {
+		t.Error("silent after playing again")
+	}
+	if out.Playing() != 1 {
+		t.Errorf("%d streamers playing, want 1", out.Playing())
+	}
+}
+
+func TestPauseDuringFadeIn(t *testing.T) {
+	sp, out := newNoisePlayer(t)
+	sp.Play()
+	out.Pull(100)
+
+	sp.Pause()
+	checkState(t, sp, stateFading)
+	out.Pull(fadeSamples)
+	checkState(t, sp, statePaused)
+	if p := peak(out.Pull(fadeSamples)); p != 0 {
+		t.Errorf("paused output peaks at %v, want silence", p)
+	}
+
+	// And it plays again from there
+	if err := sp.Play(); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, sp, statePlaying)
+}
+
+func TestPauseWithoutFade(t *testing.T) {
+	sp, out := newNoisePlayer(t)
+	sp.Fade = 0
+	sp.Play()
+	out.Pull(16)
+
+	sp.Pause()
+	if p := peak(out.Pull(16)); p != 0 {
+		t.Errorf("output peaks at %v right after an instant pause", p)
+	}
+	checkState(t, sp, statePaused)
+}
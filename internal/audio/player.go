@@ -0,0 +1,565 @@
+// Package audio is the sound engine: decoding, looping, mixing and
+// fading sounds, the output device, and the timers and policies that
+// change what plays.
+package audio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+
+	"rogverse.fyi/ambiantgo/internal/logging"
+)
+
+// logger tags the sound engine's log lines
+var logger = logging.For("audio")
+
+// OutputRate is the sample rate the speaker runs at. Every sound is
+// resampled to it, whatever rate it was recorded at.
+const OutputRate beep.SampleRate = 44100
+
+// DefaultBuffer is how much audio is buffered ahead of the output device
+// unless configured otherwise
+const DefaultBuffer = 100 * time.Millisecond
+
+// Player plays the mix. It belongs to one event loop, the app's in
+// internal/ui: the tray, hotkeys, timers and the remote control all reach
+// it from there. Only IsPlaying and Changed may be called from other
+// goroutines; everything else, fields included, is the loop's alone.
+// pkg/ambient serializes every method behind a mutex for use from
+// anywhere.
+type Player struct {
+	SoundsDir   string
+	Sounds      []string
+	Channels    []*Channel              // sounds in the mix, in the order they were added
+	perChannel  map[string]float64      // volume of each sound relative to the master
+	soundFX     map[string]SoundEffects // effects of each sound
+	mixer       *beep.Mixer
+	format      beep.Format // of the speaker, at OutputRate
+	stateMu     sync.Mutex
+	state       playbackState
+	changed     chan struct{}     // signalled when the state changes off the event loop
+	loadErrors  []SoundError      // sounds that failed, until LoadErrors takes them
+	unplayable  chan []SoundError // library files the background check found broken
+	Volume      float64
+	Muted       bool // silenced without losing Volume
+	baseline    float64
+	Relay       *AudioRelay
+	Cache       *PCMCache
+	Analysis    *AnalysisCache // measures sound files, nil to leave them as they are
+	TrimSilence bool           // skip the silence measured at the ends of sound files
+	Normalize   bool           // bring sound files to LoudnessTarget
+	Crossfade   time.Duration  // overlap faded across the loop point of each sound
+	SwitchFade  time.Duration  // overlap between sounds leaving and joining the mix
+	Fade        time.Duration  // how long play fades in and pause fades out
+	fader       *Fader
+	master      *effects.Volume // master volume of the mix, changed live
+	EQ          EQ              // equalizer of the mix
+	equalizer   *equalizer
+	Reverb      Reverb // reverb of the mix
+	reverb      *reverb
+	preview     *beep.Ctrl    // sound being listened to before adding it
+	ResumeMin   time.Duration // sounds at least this long resume where they stopped
+	positions   map[string]time.Duration
+	out         Backend
+	opened      bool          // whether out has been opened
+	stale       bool          // the default device changed since out was opened
+	Buffer      time.Duration // audio buffered ahead of the device
+	Preload     int64         // sounds decoding to at most this many bytes are held in memory
+	Device      string        // output device picked from the tray, empty for the default
+}
+
+// NewPlayer creates a player for the sounds in soundsDir and the
+// built-in ones, playing on the given output device (empty for the
+// default one)
+func NewPlayer(soundsDir, device string) *Player {
+	sp := NewPlayerWithBackend(soundsDir, newBackend(device))
+	sp.Device = device
+	return sp
+}
+
+// NewPlayerWithBackend creates a player that plays through b instead of
+// an output device
+func NewPlayerWithBackend(soundsDir string, b Backend) *Player {
+	sp := &Player{
+		SoundsDir:  soundsDir,
+		perChannel: make(map[string]float64),
+		soundFX:    make(map[string]SoundEffects),
+		changed:    make(chan struct{}, 1),
+		format:     beep.Format{SampleRate: OutputRate, NumChannels: 2, Precision: 2},
+		unplayable: make(chan []SoundError, 1),
+		out:        b,
+	}
+	sp.Sounds = librarySounds(soundsDir)
+	go sp.checkLibrary(append([]string(nil), sp.Sounds...))
+	return sp
+}
+
+// Close releases the speaker and the sound files
+func (sp *Player) Close() {
+	sp.closeOutput()
+	for _, c := range sp.Channels {
+		c.close()
+	}
+}
+
+// openSound decodes a sound file, using the PCM cache when it has a copy
+func (sp *Player) openSound(filename string) (beep.StreamSeekCloser, beep.Format, error) {
+	// The starter pack is in memory already and has nothing to stat
+	if sp.Cache != nil && !isEmbeddedSound(filename) {
+		if streamer, format, ok := sp.Cache.open(filename); ok {
+			return streamer, format, nil
+		}
+	}
+
+	// Open new sound file
+	streamer, format, err := openAudio(filename)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	if sp.Cache != nil && !isEmbeddedSound(filename) {
+		sp.Cache.store(filename)
+	}
+	return streamer, format, nil
+}
+
+func (sp *Player) Play() error {
+	if len(sp.Channels) == 0 {
+		return fmt.Errorf("no sound loaded")
+	}
+
+	if err := sp.openOutput(); err != nil {
+		return err
+	}
+	// Drop what is left of the last play, e.g. a pause still fading out
+	sp.out.Clear()
+
+	// Mix every channel, each from its beginning or, for long sounds,
+	// where it last stopped
+	sp.mixer = &beep.Mixer{}
+	for _, c := range sp.Channels {
+		if c.streamer != nil {
+			c.streamer.Seek(0)
+		}
+		sp.mixer.Add(c.stream(sp.format.SampleRate, sp.ChannelVolume(c.path), sp.SoundEffects(c.path), sp.Crossfade))
+		sp.resumePosition(c)
+	}
+
+	sp.equalizer = newEqualizer(sp.mixer, sp.format.SampleRate, sp.EQ)
+	sp.reverb = newReverb(sp.equalizer, sp.format.SampleRate, sp.Reverb)
+	sp.master = &effects.Volume{
+		Streamer: sp.reverb,
+		Base:     2,
+		Volume:   sp.baseline + sp.Volume,
+		Silent:   sp.Muted || sp.Volume <= MinVolume,
+	}
+
+	// Ramp up from silence
+	sp.fader = newFader(sp.master, 0)
+	sp.fader.fadeTo(1, sp.format.SampleRate.N(sp.Fade), false)
+
+	// Mirror the output to relay listeners when enabled
+	var output beep.Streamer = sp.fader
+	if sp.Relay != nil {
+		output = sp.Relay.tap(sp.fader, sp.format.SampleRate)
+	}
+
+	sp.out.Play(output)
+	sp.setState(statePlaying)
+	return nil
+}
+
+// fadeTo ramps the mix to a gain between 0 and 1 over d while it keeps
+// playing
+func (sp *Player) fadeTo(gain float64, d time.Duration) {
+	if !sp.IsPlaying() || sp.fader == nil {
+		return
+	}
+	sp.out.Lock()
+	sp.fader.fadeTo(gain, sp.format.SampleRate.N(d), false)
+	sp.out.Unlock()
+}
+
+// Pause fades the sound out; the speaker drops it once it is silent
+func (sp *Player) Pause() {
+	if sp.IsPlaying() && sp.fader != nil {
+		sp.setState(stateFading)
+		sp.out.Lock()
+		for _, c := range sp.Channels {
+			sp.keepPosition(c)
+		}
+		sp.fader.onStop = sp.fadedOut
+		sp.fader.fadeTo(0, sp.format.SampleRate.N(sp.Fade), true)
+		sp.out.Unlock()
+		return
+	}
+
+	sp.out.Clear()
+	if sp.playbackState() != stateStopped {
+		sp.setState(statePaused)
+	}
+}
+
+func (sp *Player) SetVolume(vol float64) {
+	sp.Volume = vol
+	sp.applyVolume()
+}
+
+// SetMuted silences the mix or brings it back at its volume; the mix
+// keeps playing underneath
+func (sp *Player) SetMuted(muted bool) {
+	sp.Muted = muted
+	sp.applyVolume()
+}
+
+// SetBaseline changes the profile volume that the selected volume is relative to
+func (sp *Player) SetBaseline(baseline float64) {
+	if baseline == sp.baseline {
+		return
+	}
+	sp.baseline = baseline
+	sp.applyVolume()
+}
+
+// applyVolume updates the master volume while the mix keeps playing
+func (sp *Player) applyVolume() {
+	if sp.master == nil {
+		return
+	}
+	sp.out.Lock()
+	sp.master.Volume = sp.baseline + sp.Volume
+	sp.master.Silent = sp.Muted || sp.Volume <= MinVolume
+	sp.out.Unlock()
+}
+
+// PlayChime plays a short chime on top of whatever is playing, at the
+// master volume; it stays quiet while muted
+func (sp *Player) PlayChime() {
+	if !sp.IsPlaying() || sp.Muted || sp.Volume <= MinVolume {
+		return
+	}
+	sp.out.Play(&effects.Volume{
+		Streamer: newChime(sp.format.SampleRate),
+		Base:     2,
+		Volume:   sp.baseline + sp.Volume,
+	})
+}
+
+// PlayPreview plays a downloaded sound once, on top of the mix or alone
+// while paused; a new preview replaces the last one
+func (sp *Player) PlayPreview(data []byte) error {
+	decode, ok := decoders[headerFormat(data)]
+	if !ok {
+		return fmt.Errorf("unsupported preview format")
+	}
+	streamer, format, err := decode(io.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return err
+	}
+
+	sp.StopPreview()
+	var s beep.Streamer = streamer
+	if format.SampleRate != sp.format.SampleRate {
+		s = beep.Resample(4, format.SampleRate, sp.format.SampleRate, streamer)
+	}
+	if err := sp.openOutput(); err != nil {
+		return err
+	}
+	sp.preview = &beep.Ctrl{Streamer: s}
+	sp.out.Play(sp.preview)
+	return nil
+}
+
+// StopPreview stops the preview playing, if any
+func (sp *Player) StopPreview() {
+	if sp.preview == nil {
+		return
+	}
+	sp.out.Lock()
+	sp.preview.Streamer = nil
+	sp.out.Unlock()
+	sp.preview = nil
+}
+
+// openOutput opens the speaker the first time it is needed. It then
+// stays open, playing silence while paused, until the output device
+// changes or the player is closed.
+func (sp *Player) openOutput() error {
+	if sp.opened && sp.stale {
+		sp.closeOutput()
+	}
+	if sp.opened {
+		return nil
+	}
+	buffer := sp.Buffer
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	if err := sp.out.Init(sp.format.SampleRate, sp.format.SampleRate.N(buffer)); err != nil {
+		return err
+	}
+	sp.opened = true
+	logger.Debug("Opened the speaker", "device", sp.Device, "rate", int(sp.format.SampleRate), "buffer", buffer)
+	return nil
+}
+
+// closeOutput closes the speaker; the next play opens it again
+func (sp *Player) closeOutput() {
+	sp.out.Close()
+	sp.opened = false
+	sp.stale = false
+}
+
+// outputChanged notes that the default output device changed, so the
+// next play opens the speaker again on whichever device is current
+// rather than carrying on with the old one
+func (sp *Player) outputChanged() {
+	if sp.opened {
+		sp.stale = true
+	}
+}
+
+// reopen restarts playback so the speaker is opened on the current default
+// output device, continuing from the same position in every sound
+func (sp *Player) reopen() {
+	sp.restart(sp.closeOutput)
+}
+
+// SetOutput moves playback to another output device, or back to the
+// default one for an empty name
+func (sp *Player) SetOutput(device string) {
+	sp.restart(func() {
+		sp.closeOutput()
+		sp.out = newBackend(device)
+		sp.Device = device
+	})
+}
+
+// restart stops the mix, runs between (if any) and starts it again where
+// it left off. Nothing has to be restarted while paused, the next play
+// opens the speaker again if between closed it.
+func (sp *Player) restart(between func()) {
+	if !sp.IsPlaying() {
+		if between != nil {
+			between()
+		}
+		return
+	}
+
+	// Generated sources have no position to keep
+	positions := make(map[*Channel]int)
+	for _, c := range sp.Channels {
+		if p := c.position(); p >= 0 {
+			positions[c] = p
+		}
+	}
+
+	sp.Pause()
+	if between != nil {
+		between()
+	}
+	if err := sp.Play(); err != nil {
+		logger.Error("Reopening the speaker failed", "err", err)
+		return
+	}
+
+	// Carry on where the old device left off, with only a short fade to
+	// hide the switch
+	sp.out.Lock()
+	for c, position := range positions {
+		c.seek(position)
+	}
+	sp.fader.fadeTo(1, sp.format.SampleRate.N(100*time.Millisecond), false)
+	sp.out.Unlock()
+}
+
+// Channel returns the channel playing path, or nil if it isn't in the mix
+func (sp *Player) Channel(path string) *Channel {
+	for _, c := range sp.Channels {
+		if c.path == path {
+			return c
+		}
+	}
+	return nil
+}
+
+// AddSound adds a sound to the mix, starting it right away if playing
+func (sp *Player) AddSound(path string) error {
+	if sp.Channel(path) != nil {
+		return nil
+	}
+
+	c, err := sp.openChannel(path)
+	if err != nil {
+		sp.failed(path, err)
+		return err
+	}
+	sp.Channels = append(sp.Channels, c)
+	logger.Debug("Added a sound to the mix", "sound", path)
+
+	if sp.IsPlaying() {
+		if c.streamer != nil {
+			c.streamer.Seek(0)
+		}
+		s := c.stream(sp.format.SampleRate, sp.ChannelVolume(path), sp.SoundEffects(path), sp.Crossfade)
+		sp.resumePosition(c)
+		c.fader.fadeTo(0, 0, false)
+		c.fader.fadeTo(1, sp.format.SampleRate.N(sp.SwitchFade), false)
+		sp.out.Lock()
+		sp.mixer.Add(s)
+		sp.out.Unlock()
+	}
+	return nil
+}
+
+// RemoveSound takes a sound out of the mix, leaving the others playing
+func (sp *Player) RemoveSound(path string) {
+	c := sp.Channel(path)
+	if c == nil {
+		return
+	}
+
+	for i := range sp.Channels {
+		if sp.Channels[i] == c {
+			sp.Channels = append(sp.Channels[:i], sp.Channels[i+1:]...)
+			break
+		}
+	}
+	if sp.IsPlaying() {
+		sp.out.Lock()
+		sp.keepPosition(c)
+		sp.out.Unlock()
+	}
+
+	// Fade the sound out while the rest of the mix carries on, and only
+	// then let go of it
+	if sp.IsPlaying() && c.ctrl != nil && sp.SwitchFade > 0 {
+		sp.out.Lock()
+		c.fader.fadeTo(0, sp.format.SampleRate.N(sp.SwitchFade), true)
+		sp.out.Unlock()
+
+		out := sp.out
+		time.AfterFunc(sp.SwitchFade+time.Second, func() {
+			out.Lock()
+			c.ctrl.Streamer = nil
+			out.Unlock()
+			c.close()
+		})
+		return
+	}
+
+	// The mixer drops the channel once its ctrl has nothing to stream
+	if c.ctrl != nil {
+		sp.out.Lock()
+		c.ctrl.Streamer = nil
+		sp.out.Unlock()
+	}
+	c.close()
+}
+
+// ToggleSound adds a sound to the mix, or removes it if already there
+func (sp *Player) ToggleSound(path string) {
+	if sp.Channel(path) != nil {
+		sp.RemoveSound(path)
+		return
+	}
+	if err := sp.AddSound(path); err != nil {
+		logger.Error("Loading a sound failed", "sound", path, "err", err)
+	}
+}
+
+// ReloadSound reopens a sound in the mix so changed settings apply,
+// starting it over from the top of its loop
+func (sp *Player) ReloadSound(path string) {
+	if sp.Channel(path) == nil {
+		return
+	}
+	sp.RemoveSound(path)
+	delete(sp.positions, path)
+	if err := sp.AddSound(path); err != nil {
+		logger.Error("Loading a sound failed", "sound", path, "err", err)
+	}
+}
+
+// NowPlaying describes the sounds in the mix and whether they are playing
+func (sp *Player) NowPlaying() string {
+	if len(sp.Channels) == 0 {
+		return "No sound loaded"
+	}
+
+	var names []string
+	for _, c := range sp.Channels {
+		names = append(names, SoundName(c.path))
+	}
+
+	if sp.IsPlaying() {
+		return "Playing: " + strings.Join(names, " + ")
+	}
+	return "Paused: " + strings.Join(names, " + ")
+}
+
+// SelectSound replaces the whole mix with a single sound, crossfading
+// from the old sounds to the new one if playing. The old sounds keep
+// playing if the new one can't be loaded.
+func (sp *Player) SelectSound(path string) {
+	if err := sp.AddSound(path); err != nil {
+		logger.Error("Loading a sound failed", "sound", path, "err", err)
+		return
+	}
+
+	for _, c := range append([]*Channel(nil), sp.Channels...) {
+		if c.path != path {
+			sp.RemoveSound(c.path)
+		}
+	}
+}
+
+// setMix replaces the whole mix with the given sounds, keeping the ones
+// that are already playing. The old mix keeps playing if none of the
+// sounds can be loaded.
+func (sp *Player) setMix(paths []string) {
+	keep := make(map[string]bool)
+	for _, path := range paths {
+		if err := sp.AddSound(path); err != nil {
+			logger.Error("Loading a sound failed", "sound", path, "err", err)
+			continue
+		}
+		keep[path] = true
+	}
+	if len(keep) == 0 && len(paths) > 0 {
+		return
+	}
+
+	for _, c := range append([]*Channel(nil), sp.Channels...) {
+		if !keep[c.path] {
+			sp.RemoveSound(c.path)
+		}
+	}
+}
+
+// SoundName returns the display name of a sound, without folder or extension
+func SoundName(path string) string {
+	if isNoiseSound(path) {
+		kind := strings.TrimPrefix(path, noisePrefix)
+		if kind == "" {
+			return "Noise"
+		}
+		return strings.ToUpper(kind[:1]) + kind[1:] + " noise"
+	}
+	if isToneSound(path) {
+		return toneName(path)
+	}
+	if IsStream(path) {
+		return strings.TrimPrefix(path, streamPrefix)
+	}
+	path = strings.TrimPrefix(path, embeddedPrefix)
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
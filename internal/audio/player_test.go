@@ -0,0 +1,270 @@
+package audio
+
+import (
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+// writeSound saves samples as a 16-bit WAV file at OutputRate, so the
+// player plays them back unresampled
+func writeSound(t *testing.T, samples [][2]float64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data := &samplesStreamer{samples: samples}
+	format := beep.Format{SampleRate: OutputRate, NumChannels: 2, Precision: 2}
+	if err := wav.Encode(f, data, format); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type samplesStreamer struct {
+	samples [][2]float64
+	pos     int
+}
+
+func (s *samplesStreamer) Stream(samples [][2]float64) (int, bool) {
+	if s.pos >= len(s.samples) {
+		return 0, false
+	}
+	n := copy(samples, s.samples[s.pos:])
+	s.pos += n
+	return n, true
+}
+
+func (s *samplesStreamer) Err() error { return nil }
+
+// constant returns n samples of the same value on both sides
+func constant(n int, v float64) [][2]float64 {
+	samples := make([][2]float64, n)
+	for i := range samples {
+		samples[i] = [2]float64{v, v}
+	}
+	return samples
+}
+
+// newFilePlayer returns a player that plays the given sound through a
+// FakeBackend, without fades unless the test sets them
+func newFilePlayer(t *testing.T, samples [][2]float64) (*Player, *FakeBackend, string) {
+	t.Helper()
+	out := &FakeBackend{}
+	sp := NewPlayerWithBackend(t.TempDir(), out)
+	path := writeSound(t, samples)
+	if err := sp.AddSound(path); err != nil {
+		t.Fatal(err)
+	}
+	return sp, out, path
+}
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// tolerance allows for the sounds being stored as 16-bit samples
+const tolerance = 1e-4
+
+func TestVolumesMultiply(t *testing.T) {
+	const level = 0.5
+	sp, out, path := newFilePlayer(t, constant(4410, level))
+	sp.SetChannelVolume(path, -1) // half
+	sp.SetVolume(-1)              // half again
+	if err := sp.Play(); err != nil {
+		t.Fatal(err)
+	}
+	out.Pull(1000)
+	for _, s := range out.Pull(1000) {
+		if !near(s[0], level/4, tolerance) || !near(s[1], level/4, tolerance) {
+			t.Fatalf("sample is %v, want %v × ½ × ½", s, level)
+		}
+	}
+
+	// Changed while playing
+	sp.SetChannelVolume(path, 0)
+	if s := out.Pull(1)[0]; !near(s[0], level/2, tolerance) {
+		t.Errorf("sample is %v after raising the sound, want %v", s, level/2)
+	}
+	sp.SetVolume(MinVolume)
+	if s := out.Pull(1)[0]; s[0] != 0 {
+		t.Errorf("sample is %v at the lowest master volume, want silence", s)
+	}
+}
+
+func TestSoundsMix(t *testing.T) {
+	sp, out, _ := newFilePlayer(t, constant(4410, 0.25))
+	other := writeSound(t, constant(4410, 0.125))
+	if err := sp.AddSound(other); err != nil {
+		t.Fatal(err)
+	}
+	sp.SetChannelVolume(other, 1) // double
+	sp.Play()
+	out.Pull(1000)
+	want := 0.25 + 2*0.125
+	if s := out.Pull(1)[0]; !near(s[0], want, tolerance) {
+		t.Errorf("sample is %v, want the sum of both sounds, %v", s, want)
+	}
+}
+
+func TestFadeCurve(t *testing.T) {
+	const level = 0.5
+	sp, out, _ := newFilePlayer(t, constant(44100, level))
+	sp.Fade = 100 * time.Millisecond
+	sp.Play()
+
+	// The gain is squared along a linear ramp over 4410 samples
+	samples := out.Pull(4410 * 2)
+	for _, at := range []int{1102, 2205, 3307} {
+		gain := float64(at+1) / 4410
+		if want := level * gain * gain; !near(samples[at][0], want, 1e-3) {
+			t.Errorf("sample %d of the fade-in is %v, want %v", at, samples[at][0], want)
+		}
+	}
+	if !near(samples[6000][0], level, tolerance) {
+		t.Errorf("sample after the fade-in is %v, want %v", samples[6000][0], level)
+	}
+
+	sp.Pause()
+	samples = out.Pull(4410 * 2)
+	if !near(samples[2204][0], level/4, 1e-3) {
+		t.Errorf("halfway through the fade-out the sample is %v, want %v", samples[2204][0], level/4)
+	}
+	for i := 1; i < 4410; i++ {
+		if samples[i][0] > samples[i-1][0] {
+			t.Fatalf("fade-out rises at sample %d", i)
+		}
+	}
+	if samples[5000][0] != 0 {
+		t.Errorf("sample after the fade-out is %v, want silence", samples[5000][0])
+	}
+}
+
+// ramp rises from 0 to nearly 0.5 over n samples, so a loop that restarts
+// abruptly jumps by its whole height
+func ramp(n int) [][2]float64 {
+	samples := make([][2]float64, n)
+	for i := range samples {
+		v := 0.5 * float64(i) / float64(n)
+		samples[i] = [2]float64{v, v}
+	}
+	return samples
+}
+
+// maxStep returns the largest change between neighbouring samples
+func maxStep(samples [][2]float64) float64 {
+	var step float64
+	for i := 1; i < len(samples); i++ {
+		step = max(step, math.Abs(samples[i][0]-samples[i-1][0]))
+	}
+	return step
+}
+
+func TestLoopWithoutCrossfade(t *testing.T) {
+	const n = 4410
+	sound := ramp(n)
+	sp, out, _ := newFilePlayer(t, sound)
+	sp.Play()
+
+	samples := out.Pull(3 * n)
+	for _, at := range []int{0, 100, n - 1} {
+		want := sound[at][0]
+		if !near(samples[at][0], want, tolerance) || !near(samples[n+at][0], want, tolerance) {
+			t.Errorf("sample %d of the first two passes is %v and %v, want %v", at, samples[at][0], samples[n+at][0], want)
+		}
+	}
+	if step, want := maxStep(samples), sound[n-1][0]; !near(step, want, tolerance) {
+		t.Errorf("largest step is %v, want the %v jump where the ramp restarts", step, want)
+	}
+}
+
+func TestLoopCrossfadesTheSeam(t *testing.T) {
+	const n, overlap = 4410, 441
+	sound := ramp(n)
+	sp, out, _ := newFilePlayer(t, sound)
+	sp.Crossfade = OutputRate.D(overlap)
+	sp.Play()
+
+	// Each pass is the sound less the overlap, which is mixed into the
+	// start of the next one
+	period := n - overlap
+	samples := out.Pull(3 * period)
+	for _, at := range []int{0, 100, period - 1} {
+		if want := sound[at][0]; !near(samples[at][0], want, tolerance) {
+			t.Errorf("sample %d is %v, want %v", at, samples[at][0], want)
+		}
+	}
+
+	// The seam starts on the tail and ends on the head, with an
+	// equal-power fade in between
+	if want := sound[period][0]; !near(samples[period][0], want, tolerance) {
+		t.Errorf("first sample of the seam is %v, want the tail, %v", samples[period][0], want)
+	}
+	x := float64(overlap/2) / overlap * math.Pi / 2
+	head, tail := sound[overlap/2][0], sound[period+overlap/2][0]
+	if want, got := head*math.Sin(x)+tail*math.Cos(x), samples[period+overlap/2][0]; !near(got, want, tolerance) {
+		t.Errorf("middle of the seam is %v, want %v", got, want)
+	}
+	if want := sound[overlap][0]; !near(samples[period+overlap][0], want, tolerance) {
+		t.Errorf("sample after the seam is %v, want the body of the next pass, %v", samples[period+overlap][0], want)
+	}
+
+	if step := maxStep(samples); step > 0.01 {
+		t.Errorf("largest step is %v, want no jump at the seam", step)
+	}
+}
+
+func TestStarterSoundsAreNormalized(t *testing.T) {
+	tone := make([][2]float64, OutputRate.N(time.Second))
+	for i := range tone {
+		v := 0.1 * math.Sin(2*math.Pi*1000*float64(i)/float64(OutputRate))
+		tone[i] = [2]float64{v, v}
+	}
+	data, err := os.ReadFile(writeSound(t, tone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(starter fs.FS) { StarterSounds = starter }(StarterSounds)
+	StarterSounds = fstest.MapFS{"tone.wav": {Data: data}}
+
+	sp := NewPlayerWithBackend(t.TempDir(), &FakeBackend{})
+	sp.Analysis = LoadAnalysisCache(filepath.Join(t.TempDir(), "analysis.json"))
+	sp.Normalize = true
+	const path = embeddedPrefix + "tone.wav"
+	if err := sp.AddSound(path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case analysed := <-sp.Analysed():
+		sp.ApplyAnalysis(analysed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the starter sound was never analysed")
+	}
+	if gain := sp.Channel(path).gain; gain == 0 {
+		t.Error("the starter sound has no normalization gain")
+	}
+}
+
+func TestSoundName(t *testing.T) {
+	for path, want := range map[string]string{
+		"/sounds/Rain.ogg":          "Rain",
+		embeddedPrefix + "Fire.mp3": "Fire",
+		noisePrefix + "brown":       "Brown noise",
+		noisePrefix:                 "Noise",
+		streamPrefix + "Jazz Radio": "Jazz Radio",
+	} {
+		if got := SoundName(path); got != want {
+			t.Errorf("SoundName(%q) is %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package audio
+
+import (
+	"fmt"
+
+	"github.com/faiface/beep"
+)
+
+// preloaded is a sound file held in memory, so looping it reads nothing
+// from disk and keeps no file open
+type preloaded struct {
+	beep.StreamSeeker
+}
+
+func (preloaded) Err() error   { return nil }
+func (preloaded) Close() error { return nil }
+
+// preload decodes a short sound into memory and closes its file. Sounds
+// that would take more than sp.Preload bytes are streamed from disk as
+// before.
+func (sp *Player) preload(s beep.StreamSeekCloser, format beep.Format) (beep.StreamSeekCloser, error) {
+	f := beep.Format{SampleRate: format.SampleRate, NumChannels: 2, Precision: format.Precision}
+	if f.Precision < 1 || f.Precision > 3 {
+		f.Precision = 2
+	}
+	if s.Len() <= 0 || int64(s.Len())*int64(f.Width()) > sp.Preload {
+		return s, nil
+	}
+
+	buf := beep.NewBuffer(f)
+	buf.Append(s)
+	err := s.Err()
+	s.Close()
+	if err != nil {
+		return nil, fmt.Errorf("decoding: %w", err)
+	}
+	return preloaded{buf.Streamer(0, buf.Len())}, nil
+}
@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"strings"
+)
+
+// Preset is a saved mix that can be recalled from the Presets menu
+type Preset struct {
+	Name   string   `yaml:"name"`
+	Sounds []string `yaml:"sounds"`
+	// Volumes of each sound relative to the master volume
+	Volumes map[string]float64 `yaml:"volumes,omitempty"`
+	Volume  float64            `yaml:"volume"`
+	// Effects of each sound
+	Effects map[string]SoundEffects `yaml:"effects,omitempty"`
+	// EQ and Reverb are the effects of the mix, left as they are when
+	// missing
+	EQ     *EQ     `yaml:"eq,omitempty"`
+	Reverb *Reverb `yaml:"reverb,omitempty"`
+}
+
+// CurrentPreset captures the mix as a preset named after its sounds
+func (sp *Player) CurrentPreset() Preset {
+	eq, reverb := sp.EQ, sp.Reverb
+	p := Preset{Volumes: make(map[string]float64), Volume: sp.Volume, EQ: &eq, Reverb: &reverb}
+
+	var names []string
+	for _, c := range sp.Channels {
+		p.Sounds = append(p.Sounds, c.path)
+		names = append(names, SoundName(c.path))
+		if volume := sp.ChannelVolume(c.path); volume != 0 {
+			p.Volumes[c.path] = volume
+		}
+		if fx := sp.SoundEffects(c.path); fx != (SoundEffects{}) {
+			if p.Effects == nil {
+				p.Effects = make(map[string]SoundEffects)
+			}
+			p.Effects[c.path] = fx
+		}
+	}
+	p.Name = strings.Join(names, " + ")
+	return p
+}
+
+// ApplyPreset replaces the mix, volumes and effects with the preset's
+func (sp *Player) ApplyPreset(p Preset) {
+	for _, sound := range p.Sounds {
+		sp.SetChannelVolume(sound, p.Volumes[sound])
+		sp.SetSoundEffects(sound, p.Effects[sound])
+	}
+	sp.setMix(p.Sounds)
+	sp.SetVolume(p.Volume)
+	if p.EQ != nil {
+		sp.SetEQ(*p.EQ)
+	}
+	if p.Reverb != nil {
+		sp.SetReverb(*p.Reverb)
+	}
+}
@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VolumeProfile holds the baseline volumes for day and night. The volume
+// picked from the menu is applied on top of the baseline for the current
+// time, so evenings stay quieter without touching the menu.
+type VolumeProfile struct {
+	Day        float64
+	Night      float64
+	NightStart time.Duration // time of day the night profile starts
+	NightEnd   time.Duration // time of day the night profile ends
+}
+
+// ParseTimeWindow parses a window such as "21:00-07:00" into times of day
+func ParseTimeWindow(window string) (start, end time.Duration, err error) {
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", window)
+	}
+
+	if start, err = parseTimeOfDay(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseTimeOfDay(to); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// isNight reports whether t falls in the night window, which may wrap
+// around midnight
+func (p VolumeProfile) isNight(t time.Time) bool {
+	now := timeOfDay(t)
+	if p.NightStart <= p.NightEnd {
+		return now >= p.NightStart && now < p.NightEnd
+	}
+	return now >= p.NightStart || now < p.NightEnd
+}
+
+// Baseline returns the profile volume for the time t
+func (p VolumeProfile) Baseline(t time.Time) float64 {
+	if p.isNight(t) {
+		return p.Night
+	}
+	return p.Day
+}
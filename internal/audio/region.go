@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp is a point in a sound file, written as "1:02:03.5", "2:03.5"
+// or in seconds
+type Timestamp time.Duration
+
+func (t Timestamp) String() string {
+	d := time.Duration(t)
+	h, m := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	s := strconv.FormatFloat((d % time.Minute).Seconds(), 'f', -1, 64)
+	if (d % time.Minute) < 10*time.Second {
+		s = "0" + s
+	}
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%s", h, m, s)
+	}
+	return fmt.Sprintf("%d:%s", m, s)
+}
+
+// ParseTimestamp reads a timestamp written as String writes it
+func ParseTimestamp(s string) (Timestamp, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	var seconds float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 || i < len(parts)-1 && v != float64(int(v)) {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		seconds = seconds*60 + v
+	}
+	return Timestamp(seconds * float64(time.Second)), nil
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON accepts a timestamp string or a number of seconds
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*t = Timestamp(seconds * float64(time.Second))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTimestamp(s)
+	*t = parsed
+	return err
+}
+
+// LoopRegion returns a sound's loop markers as "start-end", or an empty
+// string if it has none
+func LoopRegion(path string) string {
+	meta := loadSoundMeta(path)
+	if !meta.hasLoopRegion() {
+		return ""
+	}
+	region := meta.LoopStart.String() + "-"
+	if meta.LoopEnd > 0 {
+		region += meta.LoopEnd.String()
+	}
+	return region
+}
+
+// SetLoopRegion stores loop markers written as "start-end", e.g.
+// "0:08-59:30", in a sound's metadata file. Without an end the loop runs
+// to the end of the file; an empty region removes the markers.
+func SetLoopRegion(path, region string) error {
+	var start, end Timestamp
+	if region = strings.TrimSpace(region); region != "" {
+		from, to, ok := strings.Cut(region, "-")
+		if !ok {
+			return fmt.Errorf("invalid loop region %q, expected start-end", region)
+		}
+		var err error
+		if start, err = ParseTimestamp(from); err != nil {
+			return err
+		}
+		if strings.TrimSpace(to) != "" {
+			if end, err = ParseTimestamp(to); err != nil {
+				return err
+			}
+		}
+		if end != 0 && end <= start {
+			return fmt.Errorf("the loop region ends before it starts")
+		}
+	}
+
+	meta := loadSoundMeta(path)
+	meta.LoopStart, meta.LoopEnd = start, end
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".json", data, 0o644)
+}
+
+// hasLoopRegion reports whether loop markers are set
+func (m SoundMeta) hasLoopRegion() bool {
+	return m.LoopStart > 0 || m.LoopEnd > 0
+}
@@ -0,0 +1,153 @@
+package audio
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/faiface/beep"
+
+	"rogverse.fyi/ambiantgo/internal/mp3"
+)
+
+// relayBitrate is the MP3 bitrate of the relay in kbit/s
+const relayBitrate = 128
+
+// AudioRelay copies the live mix to any connected HTTP listeners as a
+// never-ending MP3 stream that a browser <audio> tag can play. The mix
+// is encoded once, off the speaker, and only while someone listens.
+type AudioRelay struct {
+	mu         sync.Mutex
+	listeners  map[chan []byte]struct{}
+	sampleRate beep.SampleRate
+	pcm        chan [][2]float64 // mix waiting to be encoded
+}
+
+func NewAudioRelay() *AudioRelay {
+	r := &AudioRelay{
+		listeners:  make(map[chan []byte]struct{}),
+		sampleRate: 44100,
+		pcm:        make(chan [][2]float64, 64),
+	}
+	go r.encode()
+	return r
+}
+
+// tap wraps a streamer so everything it produces is also sent to listeners
+func (r *AudioRelay) tap(s beep.Streamer, sampleRate beep.SampleRate) beep.Streamer {
+	r.mu.Lock()
+	r.sampleRate = sampleRate
+	r.mu.Unlock()
+
+	return beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		n, ok := s.Stream(samples)
+		if n > 0 {
+			r.broadcast(samples[:n])
+		}
+		return n, ok
+	})
+}
+
+// broadcast hands a copy of the samples to the encoder
+func (r *AudioRelay) broadcast(samples [][2]float64) {
+	r.mu.Lock()
+	listening := len(r.listeners) > 0
+	r.mu.Unlock()
+	if !listening {
+		return
+	}
+
+	// Never block the speaker; when the encoder falls behind the
+	// listeners just drop audio
+	select {
+	case r.pcm <- append([][2]float64(nil), samples...):
+	default:
+	}
+}
+
+// encode turns the mix into MP3 frames and sends them to every listener.
+// Frames carry all their data, so a listener may miss some or join
+// between any two.
+func (r *AudioRelay) encode() {
+	var (
+		enc  *mp3.Encoder
+		rate beep.SampleRate
+	)
+	for samples := range r.pcm {
+		r.mu.Lock()
+		sampleRate := r.sampleRate
+		r.mu.Unlock()
+		if sampleRate != rate {
+			rate = sampleRate
+			var err error
+			if enc, err = mp3.NewEncoder(int(rate), relayBitrate); err != nil {
+				logger.Error("Encoding the relay failed", "err", err)
+			}
+		}
+		if enc == nil {
+			continue
+		}
+
+		frames := enc.Encode(samples)
+		if len(frames) == 0 {
+			continue
+		}
+		r.mu.Lock()
+		for ch := range r.listeners {
+			select {
+			case ch <- frames:
+			default:
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// ServeHTTP streams the relay to a single listener until it disconnects
+func (r *AudioRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ch := make(chan []byte, 64)
+
+	r.mu.Lock()
+	r.listeners[ch] = struct{}{}
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.listeners, ch)
+		r.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// Answer at once, even while playback is paused
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case buf := <-ch:
+			if _, err := w.Write(buf); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// StartRelay serves the relay on addr in the background
+func StartRelay(addr string, relay *AudioRelay) {
+	mux := http.NewServeMux()
+	mux.Handle("/stream", relay)
+
+	go func() {
+		logger.Info("Audio relay listening", "url", "http://"+addr+"/stream")
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("Audio relay stopped", "err", err)
+		}
+	}()
+}
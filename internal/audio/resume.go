@@ -0,0 +1,34 @@
+package audio
+
+import "time"
+
+// keepPosition remembers where a long sound is, so it resumes there the
+// next time it plays instead of starting over
+func (sp *Player) keepPosition(c *Channel) {
+	if !sp.resumes(c) {
+		return
+	}
+	if p := c.position(); p >= 0 {
+		if sp.positions == nil {
+			sp.positions = make(map[string]time.Duration)
+		}
+		sp.positions[c.path] = c.format.SampleRate.D(p)
+	}
+}
+
+// resumePosition moves a channel that has just been streamed to where its
+// sound last stopped
+func (sp *Player) resumePosition(c *Channel) {
+	if d, ok := sp.positions[c.path]; ok && sp.resumes(c) {
+		c.seek(c.format.SampleRate.N(d))
+	}
+}
+
+// resumes reports whether a channel is a file long enough to resume;
+// shorter loops, and sounds set to restart, always start from the top
+func (sp *Player) resumes(c *Channel) bool {
+	if c.streamer == nil || c.meta.Restart {
+		return false
+	}
+	return c.format.SampleRate.D(c.streamer.Len()) >= sp.ResumeMin
+}
@@ -0,0 +1,131 @@
+package audio
+
+import "github.com/faiface/beep"
+
+// Reverb gives dry recordings a space to play in. Room sets how long the
+// reverb rings, from 0 to 1, Damp how quickly its highs die away and Wet
+// how loud it is against the mix; no Wet means no reverb.
+type Reverb struct {
+	Room float64 `yaml:"room" json:"room"`
+	Damp float64 `yaml:"damp" json:"damp"`
+	Wet  float64 `yaml:"wet" json:"wet"`
+}
+
+// ReverbPresets are the rooms offered in the Reverb menu
+var ReverbPresets = []struct {
+	Name   string
+	Reverb Reverb
+}{
+	{"Off", Reverb{}},
+	{"Small room", Reverb{Room: 0.35, Damp: 0.6, Wet: 0.15}},
+	{"Hall", Reverb{Room: 0.75, Damp: 0.4, Wet: 0.25}},
+	{"Cathedral", Reverb{Room: 0.92, Damp: 0.25, Wet: 0.3}},
+}
+
+// Delays of the Freeverb filters in samples at 44.1 kHz; the right
+// channel's are longer by stereoSpread
+var (
+	combTuning    = []int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	allpassTuning = []int{556, 441, 341, 225}
+)
+
+const stereoSpread = 23
+
+// reverb is Jezar's Freeverb: eight damped comb filters in parallel,
+// then four allpass filters in series, for each channel
+type reverb struct {
+	streamer beep.Streamer
+	settings Reverb
+	combs    [2][]*comb
+	allpass  [2][]*allpass
+}
+
+func newReverb(s beep.Streamer, sampleRate beep.SampleRate, r Reverb) *reverb {
+	rv := &reverb{streamer: s}
+	scale := float64(sampleRate) / 44100
+	for ch := range rv.combs {
+		for _, n := range combTuning {
+			rv.combs[ch] = append(rv.combs[ch], &comb{buf: make([]float64, int(float64(n+ch*stereoSpread)*scale))})
+		}
+		for _, n := range allpassTuning {
+			rv.allpass[ch] = append(rv.allpass[ch], &allpass{buf: make([]float64, int(float64(n+ch*stereoSpread)*scale))})
+		}
+	}
+	rv.set(r)
+	return rv
+}
+
+// set changes the room while the mix plays, keeping the tail ringing
+func (rv *reverb) set(r Reverb) {
+	rv.settings = r
+	for ch := range rv.combs {
+		for _, c := range rv.combs[ch] {
+			c.feedback = 0.7 + 0.28*r.Room
+			c.damp = 0.4 * r.Damp
+		}
+	}
+}
+
+func (rv *reverb) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = rv.streamer.Stream(samples)
+	if rv.settings.Wet == 0 {
+		return n, ok
+	}
+	for i := range samples[:n] {
+		input := (samples[i][0] + samples[i][1]) * 0.015
+		for ch := range samples[i] {
+			var out float64
+			for _, c := range rv.combs[ch] {
+				out += c.process(input)
+			}
+			for _, a := range rv.allpass[ch] {
+				out = a.process(out)
+			}
+			samples[i][ch] += out * rv.settings.Wet * 3
+		}
+	}
+	return n, ok
+}
+
+func (rv *reverb) Err() error {
+	return rv.streamer.Err()
+}
+
+// comb is a feedback delay whose feedback is low-passed
+type comb struct {
+	buf            []float64
+	pos            int
+	store          float64
+	feedback, damp float64
+}
+
+func (c *comb) process(x float64) float64 {
+	out := c.buf[c.pos]
+	c.store = out*(1-c.damp) + c.store*c.damp
+	c.buf[c.pos] = x + c.store*c.feedback
+	c.pos = (c.pos + 1) % len(c.buf)
+	return out
+}
+
+// allpass smears the echoes of the combs without colouring them
+type allpass struct {
+	buf []float64
+	pos int
+}
+
+func (a *allpass) process(x float64) float64 {
+	delayed := a.buf[a.pos]
+	a.buf[a.pos] = x + delayed*0.5
+	a.pos = (a.pos + 1) % len(a.buf)
+	return delayed - x
+}
+
+// SetReverb changes the reverb of the mix, live if playing
+func (sp *Player) SetReverb(r Reverb) {
+	sp.Reverb = r
+	if sp.reverb != nil {
+		sp.out.Lock()
+		sp.reverb.set(r)
+		sp.out.Unlock()
+	}
+}
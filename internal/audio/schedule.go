@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleEntry is a play window from the config file, e.g. ocean sounds
+// from 09:00 to 17:30 on weekdays
+type ScheduleEntry struct {
+	// Window is the time of day to play, e.g. "09:00-17:30"
+	Window string `yaml:"window"`
+	// Days the window applies to, e.g. [mon, tue]; empty means every day
+	Days []string `yaml:"days,omitempty"`
+	// Sounds replace the mix when the window starts; empty keeps the mix
+	Sounds []string `yaml:"sounds,omitempty"`
+}
+
+// ScheduleWindow is a parsed ScheduleEntry
+type ScheduleWindow struct {
+	start, end time.Duration
+	days       map[time.Weekday]bool // nil means every day
+	sounds     []string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseSchedule parses the schedule from the config, skipping invalid entries
+func ParseSchedule(entries []ScheduleEntry) []ScheduleWindow {
+	var windows []ScheduleWindow
+	for _, e := range entries {
+		w, err := parseScheduleEntry(e)
+		if err != nil {
+			logger.Warn("Ignoring a schedule entry", "err", err)
+			continue
+		}
+		windows = append(windows, w)
+	}
+	return windows
+}
+
+func parseScheduleEntry(e ScheduleEntry) (ScheduleWindow, error) {
+	var w ScheduleWindow
+	var err error
+	w.start, w.end, err = ParseTimeWindow(e.Window)
+	if err != nil {
+		return w, err
+	}
+
+	for _, day := range e.Days {
+		day := strings.ToLower(day)
+		weekday, ok := weekdays[day[:min(3, len(day))]]
+		if !ok {
+			return w, fmt.Errorf("invalid day %q", day)
+		}
+		if w.days == nil {
+			w.days = make(map[time.Weekday]bool)
+		}
+		w.days[weekday] = true
+	}
+
+	w.sounds = e.Sounds
+	return w, nil
+}
+
+// startsAt reports whether the window starts in the minute of t
+func (w ScheduleWindow) startsAt(t time.Time) bool {
+	return timeOfDay(t) == w.start && w.onDay(t.Weekday())
+}
+
+// stopsAt reports whether the window ends in the minute of t; a window
+// that wraps past midnight ends on the day after it started
+func (w ScheduleWindow) stopsAt(t time.Time) bool {
+	day := t.Weekday()
+	if w.end <= w.start {
+		day = (day + 6) % 7
+	}
+	return timeOfDay(t) == w.end && w.onDay(day)
+}
+
+// openAt reports whether t falls inside the window
+func (w ScheduleWindow) openAt(t time.Time) bool {
+	day, tod := t.Weekday(), timeOfDay(t)
+	if w.start < w.end {
+		return tod >= w.start && tod < w.end && w.onDay(day)
+	}
+	return tod >= w.start && w.onDay(day) || tod < w.end && w.onDay((day+6)%7)
+}
+
+// lastEdge returns the latest start or end of the window in (since, now].
+// Looking back a week covers every edge the window has.
+func (w ScheduleWindow) lastEdge(since, now time.Time) (started, stopped bool) {
+	t := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, now.Location())
+	for i := 0; i <= 7*24*60 && t.After(since); i++ {
+		if w.startsAt(t) {
+			return true, false
+		}
+		if w.stopsAt(t) {
+			return false, true
+		}
+		t = t.Add(-time.Minute)
+	}
+	return false, false
+}
+
+func (w ScheduleWindow) onDay(day time.Weekday) bool {
+	return w.days == nil || w.days[day]
+}
+
+// timeOfDay returns the hours and minutes of t as a duration since midnight
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// RunSchedule starts or stops playback for windows that began or ended
+// since the last check, so a check that comes late, after the computer
+// slept or a busy minute, still catches the edge; only the latest edge
+// of each window counts. A zero since is the launch, which starts the
+// windows already open. Changes only happen at the edges of a window, so
+// playing or pausing from the tray in between is left alone. It reports
+// whether a window started while canPlay was false, so the caller can
+// start it once playback is allowed.
+func (sp *Player) RunSchedule(windows []ScheduleWindow, since, now time.Time, canPlay bool) (refused bool) {
+	var starting []ScheduleWindow
+	for _, w := range windows {
+		var started, stopped bool
+		if since.IsZero() {
+			started = w.openAt(now)
+		} else {
+			started, stopped = w.lastEdge(since, now)
+		}
+		if stopped {
+			sp.Pause()
+		}
+		if started {
+			starting = append(starting, w)
+		}
+	}
+
+	// Starts go after the stops, so a window opening as another closes
+	// keeps playing
+	for _, w := range starting {
+		if len(w.sounds) > 0 {
+			sp.setMix(w.sounds)
+		}
+		switch {
+		case sp.IsPlaying():
+		case !canPlay:
+			refused = true
+		default:
+			if err := sp.Play(); err != nil {
+				logger.Error("Starting scheduled playback failed", "err", err)
+			}
+		}
+	}
+	return refused
+}
@@ -0,0 +1,16 @@
+package audio
+
+import "os"
+
+// sessionIconName is the icon-theme name mixers show for the app;
+// PulseAudio takes a theme name, not a file, and the app icon isn't
+// installed in a theme
+const sessionIconName = "audio-x-generic"
+
+// RegisterAudioSession sets the stream properties read by the PulseAudio
+// ALSA plugin, so pavucontrol and similar mixers show the app name and an
+// icon. The icon file is only used on Windows.
+func RegisterAudioSession(name, iconPath string) {
+	os.Setenv("PULSE_PROP_application.name", name)
+	os.Setenv("PULSE_PROP_application.icon_name", sessionIconName)
+}
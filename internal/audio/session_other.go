@@ -0,0 +1,6 @@
+//go:build !windows && !linux
+
+package audio
+
+// RegisterAudioSession is a no-op where the OS names audio streams itself
+func RegisterAudioSession(name, iconPath string) {}
@@ -0,0 +1,62 @@
+package audio
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"rogverse.fyi/ambiantgo/internal/winapi"
+)
+
+// RegisterAudioSession names the process' default audio session, which is
+// the one the speaker plays into, so the Windows volume mixer shows the app
+// name and icon. Volume and mute set in the mixer apply to it automatically.
+func RegisterAudioSession(name, iconPath string) {
+	err := withDeviceEnumerator(func(enumerator unsafe.Pointer) error {
+		return setSessionDisplay(enumerator, name, iconPath)
+	})
+	if err != nil {
+		logger.Error("Registering the audio session failed", "err", err)
+	}
+}
+
+func setSessionDisplay(enumerator unsafe.Pointer, name, iconPath string) error {
+	device, err := defaultOutputDevice(enumerator)
+	if err != nil {
+		return err
+	}
+	defer winapi.ComRelease(device)
+
+	var manager unsafe.Pointer
+	if hr := winapi.ComCall(device, immDeviceActivate, uintptr(unsafe.Pointer(&iidIAudioSessionManager)), clsctxAll, 0, uintptr(unsafe.Pointer(&manager))); int32(hr) < 0 {
+		return fmt.Errorf("activating session manager failed: 0x%08x", uint32(hr))
+	}
+	defer winapi.ComRelease(manager)
+
+	// A nil session GUID selects the process' default session
+	var control unsafe.Pointer
+	if hr := winapi.ComCall(manager, iaudioSessionManagerGetSessionCtrl, 0, 0, uintptr(unsafe.Pointer(&control))); int32(hr) < 0 {
+		return fmt.Errorf("getting session control failed: 0x%08x", uint32(hr))
+	}
+	defer winapi.ComRelease(control)
+
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if hr := winapi.ComCall(control, iaudioSessionControlSetDisplayName, uintptr(unsafe.Pointer(namePtr)), 0); int32(hr) < 0 {
+		return fmt.Errorf("setting session name failed: 0x%08x", uint32(hr))
+	}
+
+	if iconPath != "" {
+		iconPtr, err := syscall.UTF16PtrFromString(iconPath)
+		if err != nil {
+			return err
+		}
+		if hr := winapi.ComCall(control, iaudioSessionControlSetIconPath, uintptr(unsafe.Pointer(iconPtr)), 0); int32(hr) < 0 {
+			return fmt.Errorf("setting session icon failed: 0x%08x", uint32(hr))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SleepDurations are the sleep timer lengths offered in the tray
+var SleepDurations = []time.Duration{
+	15 * time.Minute,
+	30 * time.Minute,
+	60 * time.Minute,
+	90 * time.Minute,
+}
+
+// ParseSleepDuration reads a sleep timer length typed in the tray, such as
+// "45m" or "1h30m"; a bare number is minutes
+func ParseSleepDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	d, err := time.ParseDuration(s)
+	if minutes, atoiErr := strconv.Atoi(s); atoiErr == nil {
+		d, err = time.Duration(minutes)*time.Minute, nil
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid sleep timer length %q, expected e.g. 45m or 1h30m", s)
+	}
+	return d, nil
+}
+
+// sleepFade is how long before the timer ends the sound starts fading out
+const sleepFade = 5 * time.Minute
+
+// SleepTimer fades the sound out over its last few minutes and then
+// pauses, for falling asleep to the ambience
+type SleepTimer struct {
+	timer  *time.Timer
+	End    time.Time
+	fade   time.Duration
+	fading bool
+}
+
+func NewSleepTimer() *SleepTimer {
+	t := time.NewTimer(time.Hour)
+	t.Stop()
+	return &SleepTimer{timer: t}
+}
+
+// Start arms the timer to stop playback after d, cancelling any earlier one
+func (s *SleepTimer) Start(sp *Player, d time.Duration) {
+	s.Cancel(sp)
+
+	// Short timers fade over their last third instead
+	s.fade = sleepFade
+	if s.fade > d/3 {
+		s.fade = d / 3
+	}
+	s.End = time.Now().Add(d)
+	s.timer.Reset(d - s.fade)
+}
+
+// Cancel disarms the timer, bringing the sound back up if it was fading
+func (s *SleepTimer) Cancel(sp *Player) {
+	s.timer.Stop()
+	if s.fading && sp.IsPlaying() {
+		sp.fadeTo(1, sp.Fade)
+	}
+	s.fading = false
+	s.End = time.Time{}
+}
+
+// Active reports whether the timer is armed
+func (s *SleepTimer) Active() bool {
+	return !s.End.IsZero()
+}
+
+// C returns the channel the timer fires on
+func (s *SleepTimer) C() <-chan time.Time {
+	return s.timer.C
+}
+
+// Fire starts the fade-out the first time and pauses the second time; it
+// returns true once playback has been stopped
+func (s *SleepTimer) Fire(sp *Player) bool {
+	if !s.fading {
+		s.fading = true
+		sp.fadeTo(0, s.fade)
+		s.timer.Reset(s.fade)
+		return false
+	}
+
+	sp.Pause()
+	s.fading = false
+	s.End = time.Time{}
+	return true
+}
@@ -0,0 +1,210 @@
+package audio
+
+import (
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// SoundEffects change how one sound in the mix sounds, so a single
+// recording can be heard from another room or as a lighter or heavier
+// version of itself, and placed left or right of the others
+type SoundEffects struct {
+	// LowPass is the cutoff in Hz above which the sound is muffled, 0 to
+	// leave it open
+	LowPass float64 `yaml:"low_pass,omitempty" json:"low_pass,omitempty"`
+	// Intensity thins the sound out towards -1, leaving only its loudest
+	// moments, and fills it in towards 1, e.g. drizzle to downpour
+	Intensity float64 `yaml:"intensity,omitempty" json:"intensity,omitempty"`
+	// Pan places the sound from -1 (left) to 1 (right)
+	Pan float64 `yaml:"pan,omitempty" json:"pan,omitempty"`
+	// Width narrows the stereo image towards -1 (mono) and widens it
+	// towards 1 (twice as wide); 0 leaves it as recorded
+	Width float64 `yaml:"width,omitempty" json:"width,omitempty"`
+	// Speed plays the sound faster or slower, and so higher or lower, e.g.
+	// -0.1 for 10% slower and deeper
+	Speed float64 `yaml:"speed,omitempty" json:"speed,omitempty"`
+}
+
+// LowPassLevels are the muffling offered for each sound in the tray
+var LowPassLevels = []struct {
+	Name   string
+	Cutoff float64
+}{
+	{"Open", 0},
+	{"Next room", 2000},
+	{"Behind a wall", 600},
+	{"Far away", 250},
+}
+
+// IntensityLevels are the intensities offered for each sound in the tray
+var IntensityLevels = []struct {
+	Name      string
+	Intensity float64
+}{
+	{"Light", -1},
+	{"Normal", 0},
+	{"Heavy", 1},
+}
+
+// PanLevels are the positions offered for each sound in the tray
+var PanLevels = []struct {
+	Name string
+	Pan  float64
+}{
+	{"Left", -1},
+	{"Slightly left", -0.4},
+	{"Center", 0},
+	{"Slightly right", 0.4},
+	{"Right", 1},
+}
+
+// WidthLevels are the stereo widths offered for each sound in the tray
+var WidthLevels = []struct {
+	Name  string
+	Width float64
+}{
+	{"Mono", -1},
+	{"Narrow", -0.5},
+	{"As recorded", 0},
+	{"Wide", 0.5},
+}
+
+// SpeedLevels are the playback speeds offered for each sound in the tray
+var SpeedLevels = []struct {
+	Name  string
+	Speed float64
+}{
+	{"Slower (-20%)", -0.2},
+	{"Slow (-10%)", -0.1},
+	{"Normal", 0},
+	{"Fast (+10%)", 0.1},
+}
+
+// SoundEffects returns the effects of a sound
+func (sp *Player) SoundEffects(path string) SoundEffects {
+	return sp.soundFX[path]
+}
+
+// SetSoundEffects changes the effects of a sound, live if it is playing
+func (sp *Player) SetSoundEffects(path string, fx SoundEffects) {
+	if fx == (SoundEffects{}) {
+		delete(sp.soundFX, path)
+	} else {
+		sp.soundFX[path] = fx
+	}
+
+	if c := sp.Channel(path); c != nil && c.fx != nil {
+		sp.out.Lock()
+		c.fx.set(fx)
+		c.resampler.SetRatio(c.ratio(sp.format.SampleRate, fx.Speed))
+		sp.out.Unlock()
+	}
+}
+
+// filterChain applies a sound's effects to its channel
+type filterChain struct {
+	streamer   beep.Streamer
+	sampleRate beep.SampleRate
+	fx         SoundEffects
+	lowPass    [2]*biquad // cascaded for a steeper slope
+
+	// Intensity follows the level of the sound against its recent peaks
+	envelope, peak  float64
+	attack, release float64
+	peakRelease     float64
+}
+
+func newFilterChain(s beep.Streamer, sampleRate beep.SampleRate, fx SoundEffects) *filterChain {
+	f := &filterChain{
+		streamer:    s,
+		sampleRate:  sampleRate,
+		attack:      smoothing(sampleRate, 0.005),
+		release:     smoothing(sampleRate, 0.08),
+		peakRelease: smoothing(sampleRate, 2),
+	}
+	f.lowPass[0] = lowPass(sampleRate, lowPassOff, 1/math.Sqrt2)
+	f.lowPass[1] = lowPass(sampleRate, lowPassOff, 1/math.Sqrt2)
+	f.set(fx)
+	return f
+}
+
+// smoothing returns the factor of a one-pole filter with the given time
+// constant in seconds
+func smoothing(sampleRate beep.SampleRate, seconds float64) float64 {
+	return 1 - math.Exp(-1/(seconds*float64(sampleRate)))
+}
+
+// lowPassOff is the cutoff in Hz the low-pass filters keep running at
+// while muffling is off, so they are in step with the sound when it is
+// turned on
+const lowPassOff = 20000
+
+// set changes the effects while the sound plays
+func (f *filterChain) set(fx SoundEffects) {
+	f.fx = fx
+	cutoff := fx.LowPass
+	if cutoff <= 0 {
+		cutoff = lowPassOff
+	}
+	f.lowPass[0].retune(lowPass(f.sampleRate, cutoff, 1/math.Sqrt2))
+	f.lowPass[1].retune(lowPass(f.sampleRate, cutoff, 1/math.Sqrt2))
+}
+
+func (f *filterChain) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = f.streamer.Stream(samples)
+	for i := range samples[:n] {
+		for ch, x := range samples[i] {
+			y := f.lowPass[1].process(f.lowPass[0].process(x, ch), ch)
+			// Unmuffled sounds keep their highs
+			if f.fx.LowPass > 0 {
+				samples[i][ch] = y
+			}
+		}
+		if f.fx.Intensity != 0 {
+			f.intensify(&samples[i])
+		}
+		if f.fx.Pan != 0 || f.fx.Width != 0 {
+			f.place(&samples[i])
+		}
+	}
+	return n, ok
+}
+
+// intensify expands the sound for a negative intensity, so the quiet
+// texture between drops falls away, and compresses it for a positive
+// one, bringing that texture up to fill the gaps. The loudest moments
+// are left as they are.
+func (f *filterChain) intensify(s *[2]float64) {
+	level := math.Max(math.Abs(s[0]), math.Abs(s[1]))
+	if level > f.envelope {
+		f.envelope += (level - f.envelope) * f.attack
+	} else {
+		f.envelope += (level - f.envelope) * f.release
+	}
+	if f.envelope > f.peak {
+		f.peak = f.envelope
+	} else {
+		f.peak += (f.envelope - f.peak) * f.peakRelease
+	}
+
+	const floor = 1e-4
+	gain := math.Pow((f.envelope+floor)/(f.peak+floor), -0.7*f.fx.Intensity)
+	gain = math.Max(0.05, math.Min(gain, 3))
+	s[0] *= gain
+	s[1] *= gain
+}
+
+// place sets the stereo width, scaling the difference between the
+// channels, then pans with constant power so the sound keeps its
+// loudness as it moves
+func (f *filterChain) place(s *[2]float64) {
+	mid, side := (s[0]+s[1])/2, (s[0]-s[1])/2*(1+f.fx.Width)
+	angle := (f.fx.Pan + 1) * math.Pi / 4
+	s[0] = (mid + side) * math.Min(1, math.Sqrt2*math.Cos(angle))
+	s[1] = (mid - side) * math.Min(1, math.Sqrt2*math.Sin(angle))
+}
+
+func (f *filterChain) Err() error {
+	return f.streamer.Err()
+}
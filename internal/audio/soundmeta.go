@@ -0,0 +1,135 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// SoundMeta holds optional per-sound settings, read from a "<sound>.json"
+// file stored next to the audio file (e.g. "Rain.mp3.json")
+type SoundMeta struct {
+	// TrimStart is the number of seconds skipped at the start of the file
+	TrimStart float64 `json:"trim_start"`
+	// TrimEnd is the number of seconds dropped before the end of the file
+	TrimEnd float64 `json:"trim_end"`
+	// LoopStart and LoopEnd mark the exact part of the file that loops,
+	// instead of the trim offsets; without an end it loops to the end
+	LoopStart Timestamp `json:"loop_start,omitempty"`
+	LoopEnd   Timestamp `json:"loop_end,omitempty"`
+	// Stretch plays the sound as a granular texture this many times slower
+	// instead of looping it, for recordings too short to loop
+	Stretch float64 `json:"stretch"`
+	// Restart always plays the sound from its start, even when it is long
+	// enough to resume where it stopped
+	Restart bool `json:"restart,omitempty"`
+
+	// Category groups the sound in the Sounds menu, e.g. "Nature"
+	Category string `json:"category,omitempty"`
+
+	// Author, License and Source credit a downloaded recording
+	Author  string `json:"author,omitempty"`
+	License string `json:"license,omitempty"`
+	Source  string `json:"source,omitempty"`
+}
+
+// loadSoundMeta returns the metadata for a sound, or zero values if none exists
+func loadSoundMeta(filename string) SoundMeta {
+	var meta SoundMeta
+
+	data, err := os.ReadFile(filename + ".json")
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Reading sound metadata failed", "err", err)
+		}
+		return meta
+	}
+
+	if err := json.Unmarshal(data, &meta); err != nil {
+		logger.Error("Parsing sound metadata failed", "sound", filename, "err", err)
+		return SoundMeta{}
+	}
+	return meta
+}
+
+// SoundCredit returns who made a sound and under which license, from its
+// metadata, or an empty string if it doesn't say
+func SoundCredit(path string) string {
+	meta := loadSoundMeta(path)
+	credit := meta.Author
+	if credit != "" {
+		credit = "by " + credit
+	}
+	if meta.License != "" {
+		credit = strings.TrimSpace(credit + " (" + meta.License + ")")
+	}
+	return credit
+}
+
+// trim limits a streamer to its loop region, or else to the region left
+// after applying the trim offsets
+func (m SoundMeta) trim(s beep.StreamSeeker, sampleRate beep.SampleRate) beep.StreamSeeker {
+	start := sampleRate.N(time.Duration(m.TrimStart * float64(time.Second)))
+	end := s.Len() - sampleRate.N(time.Duration(m.TrimEnd*float64(time.Second)))
+	if m.hasLoopRegion() {
+		start, end = sampleRate.N(time.Duration(m.LoopStart)), s.Len()
+		if m.LoopEnd > 0 {
+			end = sampleRate.N(time.Duration(m.LoopEnd))
+		}
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	if end > s.Len() {
+		end = s.Len()
+	}
+	if start == 0 && end == s.Len() {
+		return s
+	}
+	if end <= start {
+		logger.Warn("Ignoring a loop region outside the sound itself")
+		return s
+	}
+
+	region := &regionStreamer{streamer: s, start: start, end: end}
+	region.Seek(0)
+	return region
+}
+
+// regionStreamer plays only the samples between start and end of a streamer
+type regionStreamer struct {
+	streamer   beep.StreamSeeker
+	start, end int
+}
+
+func (r *regionStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	remaining := r.end - r.streamer.Position()
+	if remaining <= 0 {
+		return 0, false
+	}
+	if len(samples) > remaining {
+		samples = samples[:remaining]
+	}
+	return r.streamer.Stream(samples)
+}
+
+func (r *regionStreamer) Err() error {
+	return r.streamer.Err()
+}
+
+func (r *regionStreamer) Len() int {
+	return r.end - r.start
+}
+
+func (r *regionStreamer) Position() int {
+	return r.streamer.Position() - r.start
+}
+
+func (r *regionStreamer) Seek(p int) error {
+	return r.streamer.Seek(r.start + p)
+}
@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SavedState is what the player was doing when the app quit, so the next
+// launch picks up where it left off
+type SavedState struct {
+	Mix        []string                `json:"mix"`
+	PerChannel map[string]float64      `json:"channel_volumes"`
+	Volume     float64                 `json:"volume"`
+	Playing    bool                    `json:"playing"`
+	EQ         EQ                      `json:"eq"`
+	Reverb     Reverb                  `json:"reverb"`
+	Effects    map[string]SoundEffects `json:"effects,omitempty"`
+	// Positions are where long sounds stopped, in seconds
+	Positions map[string]float64 `json:"positions,omitempty"`
+}
+
+// LoadState reads the state saved at path, if there is one
+func LoadState(path string) (SavedState, bool) {
+	var state SavedState
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Reading the player state failed", "err", err)
+		}
+		return state, false
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		logger.Error("Parsing the player state failed", "err", err)
+		return SavedState{}, false
+	}
+	return state, true
+}
+
+// SaveState writes the current mix, volumes, effects, positions and
+// playing state to path
+func (sp *Player) SaveState(path string) {
+	state := SavedState{
+		PerChannel: sp.perChannel,
+		Volume:     sp.Volume,
+		Playing:    sp.IsPlaying(),
+		EQ:         sp.EQ,
+		Reverb:     sp.Reverb,
+		Effects:    sp.soundFX,
+		Positions:  make(map[string]float64),
+	}
+	playing := sp.IsPlaying()
+	if playing {
+		sp.out.Lock()
+	}
+	for _, c := range sp.Channels {
+		state.Mix = append(state.Mix, c.path)
+		if playing {
+			sp.keepPosition(c)
+		}
+	}
+	if playing {
+		sp.out.Unlock()
+	}
+	for path, d := range sp.positions {
+		state.Positions[path] = d.Seconds()
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		logger.Error("Saving the player state failed", "err", err)
+	}
+}
+
+// RestoreState rebuilds the saved mix from the sounds still in the library
+func (sp *Player) RestoreState(state SavedState) {
+	for path, volume := range state.PerChannel {
+		sp.perChannel[path] = volume
+	}
+	sp.EQ = state.EQ
+	sp.Reverb = state.Reverb
+	for path, fx := range state.Effects {
+		sp.soundFX[path] = fx
+	}
+	sp.positions = make(map[string]time.Duration)
+	for path, seconds := range state.Positions {
+		sp.positions[path] = time.Duration(seconds * float64(time.Second))
+	}
+
+	for _, path := range state.Mix {
+		listed := false
+		for _, sound := range sp.Sounds {
+			if sound == path {
+				listed = true
+				if err := sp.AddSound(path); err != nil {
+					logger.Error("Loading a sound failed", "sound", path, "err", err)
+				}
+				break
+			}
+		}
+		// A file deleted since the last run is reported; one that is
+		// there but unplayable was already reported by the scan
+		if listed || IsSynthesized(path) || IsStream(path) || isEmbeddedSound(path) {
+			continue
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			sp.failed(path, err)
+		}
+	}
+}
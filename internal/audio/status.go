@@ -0,0 +1,84 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Status is the state reported to API clients
+type Status struct {
+	Playing    bool     `json:"playing"`
+	State      string   `json:"state"` // stopped, playing, fading or paused
+	NowPlaying string   `json:"now_playing"`
+	Sounds     []string `json:"sounds"`
+	Volume     float64  `json:"volume"` // 0 to 1
+	Muted      bool     `json:"muted"`
+	Library    []string `json:"library"`
+}
+
+// MinVolume is the quietest volume, which the API and the 0% step map to
+// and which plays silence
+const MinVolume = -10
+
+// Status describes the player for API clients; it must be called from the
+// event loop
+func (sp *Player) Status() Status {
+	s := Status{
+		Playing:    sp.IsPlaying(),
+		State:      sp.playbackState().String(),
+		NowPlaying: sp.NowPlaying(),
+		Volume:     volumeFraction(sp.Volume),
+		Muted:      sp.Muted,
+		Sounds:     []string{},
+	}
+	for _, c := range sp.Channels {
+		s.Sounds = append(s.Sounds, SoundName(c.path))
+	}
+	for _, sound := range sp.Sounds {
+		s.Library = append(s.Library, SoundName(sound))
+	}
+	return s
+}
+
+// volumeFraction converts a log2 volume to a 0 to 1 level
+func volumeFraction(volume float64) float64 {
+	if volume <= MinVolume {
+		return 0
+	}
+	return math.Min(math.Pow(2, volume), 1)
+}
+
+// VolumePercent converts a log2 volume to a rounded percentage of full
+func VolumePercent(volume float64) int {
+	return int(math.Round(volumeFraction(volume) * 100))
+}
+
+// ParseVolumeFraction converts a 0 to 1 level, e.g. "0.5", to a log2 volume
+func ParseVolumeFraction(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 || f > 1 {
+		return 0, fmt.Errorf("invalid volume %q, expected 0 to 1", s)
+	}
+	return FractionVolume(f), nil
+}
+
+// FractionVolume converts a 0 to 1 level to a log2 volume
+func FractionVolume(f float64) float64 {
+	if f <= 0 {
+		return MinVolume
+	}
+	return math.Max(math.Log2(math.Min(f, 1)), MinVolume)
+}
+
+// FindSound returns the library sound matching a name as shown in the
+// menu, without extension, or a path
+func (sp *Player) FindSound(name string) (string, bool) {
+	for _, sound := range sp.Sounds {
+		if sound == name || strings.EqualFold(SoundName(sound), name) || strings.EqualFold(SoundFile(sound), name) {
+			return sound, true
+		}
+	}
+	return "", false
+}
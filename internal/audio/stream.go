@@ -0,0 +1,241 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// Stream is an internet radio station or other HTTP audio stream, e.g.
+// an Icecast mount, listed in the Sounds menu under its name
+type Stream struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// Streams are the streams from the config file
+var Streams []Stream
+
+// streamPrefix marks the sounds that play a stream
+const streamPrefix = "stream:"
+
+const (
+	// streamAhead is how much of a stream is decoded ahead of playback,
+	// enough to ride out a slow network
+	streamAhead = 5 * time.Second
+	// streamStall is how long a stream may send nothing before it is
+	// dropped and reconnected
+	streamStall = 15 * time.Second
+	// streamMaxBackoff caps the wait between reconnects
+	streamMaxBackoff = time.Minute
+)
+
+// streamClient gives up on stations that don't answer, but not on a
+// stream that keeps sending
+var streamClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: streamStall,
+	},
+}
+
+// streamSounds lists the streams as sounds for the library
+func streamSounds() []string {
+	var sounds []string
+	for _, s := range Streams {
+		sounds = append(sounds, streamPrefix+s.Name)
+	}
+	return sounds
+}
+
+// IsStream reports whether a sound is played from an HTTP stream
+func IsStream(path string) bool {
+	return strings.HasPrefix(path, streamPrefix)
+}
+
+// findStream returns the stream a sound plays
+func findStream(path string) (Stream, error) {
+	name := strings.TrimPrefix(path, streamPrefix)
+	for _, s := range Streams {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Stream{}, fmt.Errorf("unknown stream %q", name)
+}
+
+// radio plays an HTTP stream. A goroutine decodes it ahead of playback
+// at noiseFormat's rate and reconnects with backoff when it drops; the
+// mix hears silence while it waits, so the other sounds play on.
+type radio struct {
+	url    string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	buf [][2]float64 // decoded samples waiting to be played
+}
+
+func newRadio(url string) *radio {
+	r := &radio{url: url}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	go r.run()
+	return r
+}
+
+// Stream never ends
+func (r *radio) Stream(samples [][2]float64) (int, bool) {
+	r.mu.Lock()
+	n := copy(samples, r.buf)
+	r.buf = r.buf[n:]
+	r.mu.Unlock()
+	clear(samples[n:])
+	return len(samples), true
+}
+
+func (r *radio) Err() error {
+	return nil
+}
+
+// Close disconnects and stops reconnecting
+func (r *radio) Close() error {
+	r.cancel()
+	return nil
+}
+
+// run keeps the stream connected until it is closed, waiting longer
+// after each failed attempt
+func (r *radio) run() {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := r.listen()
+		if r.ctx.Err() != nil {
+			return
+		}
+		// A stream that played for a while starts over from a short wait
+		if time.Since(start) > streamMaxBackoff {
+			backoff = time.Second
+		}
+		logger.Warn("Stream interrupted, reconnecting", "url", r.url, "err", err, "wait", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-r.ctx.Done():
+			return
+		}
+		backoff = min(2*backoff, streamMaxBackoff)
+	}
+}
+
+// listen connects and decodes the stream until it ends or fails
+func (r *radio) listen() error {
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+
+	// A station that stops sending is dropped like a closed one
+	stall := time.AfterFunc(streamStall, cancel)
+	defer stall.Stop()
+	body := bufio.NewReaderSize(&stallReader{resp.Body, stall}, 64<<10)
+
+	header, _ := body.Peek(12)
+	ext := headerFormat(header)
+	if ext == "" {
+		ext = contentTypeFormat(resp.Header.Get("Content-Type"))
+	}
+	decode, ok := decoders[ext]
+	if !ok {
+		return fmt.Errorf("unsupported stream format %q", resp.Header.Get("Content-Type"))
+	}
+	streamer, format, err := decode(io.NopCloser(body))
+	if err != nil {
+		return err
+	}
+	defer streamer.Close()
+
+	var s beep.Streamer = streamer
+	if format.SampleRate != noiseFormat.SampleRate {
+		s = beep.Resample(4, format.SampleRate, noiseFormat.SampleRate, streamer)
+	}
+
+	ahead := noiseFormat.SampleRate.N(streamAhead)
+	chunk := make([][2]float64, 4096)
+	for {
+		r.mu.Lock()
+		full := len(r.buf) >= ahead
+		r.mu.Unlock()
+		if full {
+			// Pausing stops the reading here too; a server that hangs up
+			// meanwhile is reconnected once playback needs more
+			stall.Reset(streamStall)
+			select {
+			case <-time.After(100 * time.Millisecond):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		n, ok := s.Stream(chunk)
+		r.mu.Lock()
+		r.buf = append(r.buf, chunk[:n]...)
+		r.mu.Unlock()
+		if !ok {
+			if err := streamer.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("stream ended")
+		}
+	}
+}
+
+// contentTypeFormat returns the extension for a stream's content type,
+// for streams whose first bytes don't tell
+func contentTypeFormat(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/ogg", "application/ogg", "audio/vorbis":
+		return ".ogg"
+	case "audio/flac", "audio/x-flac":
+		return ".flac"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return ".wav"
+	}
+	return ""
+}
+
+// stallReader pushes a stall timer back every time data arrives
+type stallReader struct {
+	r     io.Reader
+	stall *time.Timer
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.stall.Reset(streamStall)
+	}
+	return n, err
+}
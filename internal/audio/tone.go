@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// tonePrefix marks the binaural beat presets in the sound list
+const tonePrefix = "tone:"
+
+// TonePreset is a binaural beat: a carrier tone played slightly higher in
+// one ear than the other, heard as a slow beat at the difference. The
+// presets can be extended from the config file.
+type TonePreset struct {
+	Name    string  `yaml:"name"`
+	Carrier float64 `yaml:"carrier"` // Hz
+	Beat    float64 `yaml:"beat"`    // Hz
+	// Isochronic pulses the same tone in both ears instead, which works
+	// without headphones
+	Isochronic bool `yaml:"isochronic,omitempty"`
+}
+
+// TonePresets are the beats offered in the Sounds menu
+var TonePresets = []TonePreset{
+	{Name: "Focus", Carrier: 220, Beat: 14},
+	{Name: "Relax", Carrier: 200, Beat: 10},
+	{Name: "Sleep", Carrier: 150, Beat: 3},
+}
+
+// toneSounds returns the sound list entries of the tone presets
+func toneSounds() []string {
+	var sounds []string
+	for _, p := range TonePresets {
+		sounds = append(sounds, tonePrefix+p.Name)
+	}
+	return sounds
+}
+
+// isToneSound reports whether path names a tone preset
+func isToneSound(path string) bool {
+	return strings.HasPrefix(path, tonePrefix)
+}
+
+// toneName returns the display name of a tone preset
+func toneName(path string) string {
+	name := strings.TrimPrefix(path, tonePrefix)
+	for _, p := range TonePresets {
+		if p.Name == name {
+			return fmt.Sprintf("%s beats (%g Hz)", p.Name, p.Beat)
+		}
+	}
+	return name
+}
+
+// Tone generates a binaural or isochronic beat. It is kept quiet so it
+// sits under the ambience.
+type Tone struct {
+	preset     TonePreset
+	sampleRate float64
+	phase      [2]float64 // carrier phase per ear
+	beatPhase  float64
+}
+
+// newTone returns a generator for the named preset
+func newTone(name string) (*Tone, error) {
+	for _, p := range TonePresets {
+		if p.Name == name {
+			return &Tone{preset: p, sampleRate: float64(noiseFormat.SampleRate)}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown tone preset %q", name)
+}
+
+// Stream never ends
+func (t *Tone) Stream(samples [][2]float64) (int, bool) {
+	const level = 0.1
+
+	p := t.preset
+	left := 2 * math.Pi * p.Carrier / t.sampleRate
+	right := 2 * math.Pi * (p.Carrier + p.Beat) / t.sampleRate
+	beat := 2 * math.Pi * p.Beat / t.sampleRate
+
+	for i := range samples {
+		if p.Isochronic {
+			// Both ears get the carrier, pulsed smoothly at the beat rate
+			gain := level * (0.5 - 0.5*math.Cos(t.beatPhase))
+			v := math.Sin(t.phase[0]) * gain
+			samples[i] = [2]float64{v, v}
+			t.phase[0] = math.Mod(t.phase[0]+left, 2*math.Pi)
+			t.beatPhase = math.Mod(t.beatPhase+beat, 2*math.Pi)
+			continue
+		}
+
+		samples[i] = [2]float64{math.Sin(t.phase[0]) * level, math.Sin(t.phase[1]) * level}
+		t.phase[0] = math.Mod(t.phase[0]+left, 2*math.Pi)
+		t.phase[1] = math.Mod(t.phase[1]+right, 2*math.Pi)
+	}
+	return len(samples), true
+}
+
+func (t *Tone) Err() error {
+	return nil
+}
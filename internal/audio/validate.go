@@ -0,0 +1,146 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/faiface/beep"
+)
+
+// SoundError is a sound that could not be listed or played
+type SoundError struct {
+	Path string
+	Err  error
+}
+
+func (e SoundError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Message describes the failure for a notification
+func (e SoundError) Message() string {
+	if errors.Is(e.Err, fs.ErrNotExist) {
+		return SoundName(e.Path) + " is missing"
+	}
+	return SoundName(e.Path) + " could not be read"
+}
+
+// probeSound decodes the first samples of a freshly opened file and
+// rewinds it, so a broken file fails when it is opened rather than once
+// it is in the mix
+func probeSound(s beep.StreamSeekCloser) error {
+	if s.Len() == 0 {
+		return fmt.Errorf("no audio in the file")
+	}
+	var samples [512][2]float64
+	n, _ := s.Stream(samples[:])
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("decoding: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no audio in the file")
+	}
+	return s.Seek(0)
+}
+
+// checkHeader reports why a sound of the library can't be played from its
+// first bytes alone, without decoding it. Folders, the starter sounds and
+// the built-in sounds are not checked.
+func checkHeader(path string) error {
+	if isEmbeddedSound(path) || IsSynthesized(path) || IsStream(path) {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return err
+	}
+	ext, err := sniffFormat(f)
+	if err != nil {
+		return err
+	}
+	if ext == "" {
+		return fmt.Errorf("not an audio file")
+	}
+	return nil
+}
+
+// checkLibrarySound reports why a sound of the library can't be played.
+// Folders and the starter sounds are not checked.
+func checkLibrarySound(path string) error {
+	if isEmbeddedSound(path) {
+		return nil
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return err
+	}
+	return checkSound(path)
+}
+
+// checkLibrary looks at the header of every sound of a scan of the
+// library in the background, sending those that can't be played to
+// Unplayable. Sounds are fully decoded only when added to the mix, which
+// reports them too.
+func (sp *Player) checkLibrary(sounds []string) {
+	var errs []SoundError
+	for _, sound := range sounds {
+		if err := checkHeader(sound); err != nil {
+			logger.Warn("Skipping a sound that can't be played", "sound", sound, "err", err)
+			errs = append(errs, SoundError{sound, err})
+		}
+	}
+	if len(errs) > 0 {
+		sp.unplayable <- errs
+	}
+}
+
+// Unplayable receives the library files found broken after the player was
+// created, for the event loop to pass to DropUnplayable
+func (sp *Player) Unplayable() <-chan []SoundError {
+	return sp.unplayable
+}
+
+// DropUnplayable takes broken sounds out of the library and reports them
+// by LoadErrors, returning those that were listed
+func (sp *Player) DropUnplayable(errs []SoundError) []string {
+	var dropped []string
+	for _, e := range errs {
+		if sp.RemoveFromLibrary(e.Path) {
+			sp.failed(e.Path, e.Err)
+			dropped = append(dropped, e.Path)
+		}
+	}
+	return dropped
+}
+
+// failed keeps a sound that could not be listed or played, to be
+// reported by LoadErrors
+func (sp *Player) failed(path string, err error) {
+	sp.loadErrors = append(sp.loadErrors, SoundError{path, err})
+}
+
+// LoadErrors returns the sounds that failed since it was last called
+func (sp *Player) LoadErrors() []SoundError {
+	errs := sp.loadErrors
+	sp.loadErrors = nil
+	return errs
+}
+
+// LoadErrorsMessage sums up failed sounds for a notification
+func LoadErrorsMessage(errs []SoundError) string {
+	if len(errs) == 1 {
+		return errs[0].Message() + " and was skipped."
+	}
+	names := make([]string, len(errs))
+	for i, e := range errs {
+		names[i] = SoundName(e.Path)
+	}
+	return fmt.Sprintf("%d sounds could not be loaded and were skipped: %s.", len(errs), strings.Join(names, ", "))
+}
@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RotateIntervals are the intervals of the Rotate sounds mode offered in
+// the tray
+var RotateIntervals = []time.Duration{
+	10 * time.Minute,
+	20 * time.Minute,
+	30 * time.Minute,
+	60 * time.Minute,
+}
+
+// Rotation switches to another sound at a fixed interval. C only delivers
+// ticks while rotation is on.
+type Rotation struct {
+	Interval time.Duration
+	ticker   *time.Ticker
+	C        <-chan time.Time
+}
+
+func NewRotation(interval time.Duration) *Rotation {
+	if interval <= 0 {
+		interval = 30 * time.Minute
+	}
+	r := &Rotation{Interval: interval, ticker: time.NewTicker(interval)}
+	r.ticker.Stop()
+	return r
+}
+
+// Set turns rotation on or off, counting the interval from now
+func (r *Rotation) Set(on bool) {
+	if on {
+		r.ticker.Reset(r.Interval)
+		r.C = r.ticker.C
+	} else {
+		r.ticker.Stop()
+		r.C = nil
+	}
+}
+
+func (r *Rotation) Active() bool {
+	return r.C != nil
+}
+
+// SetInterval changes the interval, restarting it if rotation is on
+func (r *Rotation) SetInterval(d time.Duration) {
+	r.Interval = d
+	if r.Active() {
+		r.Set(true)
+	}
+}
+
+// Rotate crossfades to another random sound
+func (r *Rotation) Rotate(sp *Player) {
+	if next := sp.nextVarietySound(); next != "" {
+		sp.SelectSound(next)
+	}
+}
+
+// AdjacentSound returns the sound step places after the first one in the
+// mix, wrapping around the sound list, or the first sound if nothing is
+// in the mix
+func (sp *Player) AdjacentSound(step int) string {
+	if len(sp.Sounds) == 0 {
+		return ""
+	}
+
+	current := -1
+	for i, sound := range sp.Sounds {
+		if len(sp.Channels) > 0 && sound == sp.Channels[0].path {
+			current = i
+		}
+	}
+	if current < 0 {
+		return sp.Sounds[0]
+	}
+	n := len(sp.Sounds)
+	return sp.Sounds[((current+step)%n+n)%n]
+}
+
+// nextVarietySound picks a random sound that isn't in the mix yet, or an
+// empty string if there is nothing else to switch to. Built-in noise and
+// tones are left out, variety is about the recordings, and so are streams,
+// which may not be reachable.
+func (sp *Player) nextVarietySound() string {
+	var others []string
+	for _, sound := range sp.Sounds {
+		if sp.Channel(sound) == nil && !IsSynthesized(sound) && !IsStream(sound) {
+			others = append(others, sound)
+		}
+	}
+
+	if len(others) == 0 {
+		return ""
+	}
+	return others[rand.Intn(len(others))]
+}
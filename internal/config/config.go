@@ -0,0 +1,184 @@
+// Package config reads and writes the settings file.
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+	"rogverse.fyi/ambiantgo/internal/logging"
+)
+
+// logger tags the config's log lines
+var logger = logging.For("config")
+
+// defaultHotkeys are the global hotkeys used when the config file doesn't
+// set them; an empty combination disables one
+var defaultHotkeys = map[string]string{
+	"toggle":      "Ctrl+Alt+A",
+	"volume_up":   "Ctrl+Alt+Up",
+	"volume_down": "Ctrl+Alt+Down",
+	"mute":        "Ctrl+Alt+M",
+}
+
+// Config holds the settings kept in config.yaml in the app data folder.
+// Command line flags override the values read from it.
+type Config struct {
+	// SoundsDir is the folder sounds are loaded from
+	SoundsDir string `yaml:"sounds_dir"`
+	// Volume is the volume used on devices that have none remembered
+	Volume float64 `yaml:"volume"`
+	// Autoplay starts playing as soon as the app is launched
+	Autoplay bool `yaml:"autoplay"`
+	// LastSound is the sound last picked from the tray, loaded on start
+	LastSound string `yaml:"last_sound"`
+	// OutputDevice is the audio output picked from the tray, empty for the
+	// system default
+	OutputDevice string `yaml:"output_device,omitempty"`
+	// Buffer is how much audio is buffered ahead of the output device:
+	// more stops crackling on slow machines, less lowers the latency
+	Buffer time.Duration `yaml:"buffer"`
+	// PreloadMB is the most memory, in megabytes, a sound may take to be
+	// held in memory instead of read from disk as it plays
+	PreloadMB int64 `yaml:"preload_mb"`
+	// TrayIcon is the style of the tray icon: color, or mono to match the
+	// taskbar, or white or black
+	TrayIcon string `yaml:"tray_icon"`
+	// Rotate switches to another random sound every RotateInterval
+	Rotate         bool          `yaml:"rotate"`
+	RotateInterval time.Duration `yaml:"rotate_interval"`
+	// EyeBreaks chimes every 20 minutes as a reminder to look away
+	EyeBreaks bool `yaml:"eye_breaks"`
+	// Schedule starts and stops playback at set times
+	Schedule []audio.ScheduleEntry `yaml:"schedule,omitempty"`
+	// Tones adds binaural beat presets to the built-in ones
+	Tones []audio.TonePreset `yaml:"tones,omitempty"`
+	// Streams are internet radio stations listed with the sounds
+	Streams []audio.Stream `yaml:"streams,omitempty"`
+	// CatalogURL is the sound pack catalog offered in "Get more sounds",
+	// which is hidden when it is empty
+	CatalogURL string `yaml:"catalog_url,omitempty"`
+	// FreesoundToken is a freesound.org API key, which shows the Freesound
+	// menu
+	FreesoundToken string `yaml:"freesound_token,omitempty"`
+	// APIToken must be sent with every control API request, made on the
+	// first run with -api
+	APIToken string `yaml:"api_token,omitempty"`
+	// Hotkeys maps actions (toggle, volume_up, volume_down, mute) to global
+	// key combinations such as "Ctrl+Alt+A"
+	Hotkeys map[string]string `yaml:"hotkeys"`
+	// Presets are the mixes saved from the Presets menu
+	Presets []audio.Preset `yaml:"presets,omitempty"`
+	// Favorites are the sounds pinned to the top of the Sounds menu
+	Favorites []string `yaml:"favorites,omitempty"`
+	// Recent are the sounds last added to the mix, newest first
+	Recent []string `yaml:"recent,omitempty"`
+
+	path string
+}
+
+// Load reads the config file at path, keeping defaults for any
+// setting it doesn't have
+func Load(path string) *Config {
+	c := &Config{
+		SoundsDir: audio.DefaultSoundsDir,
+		Volume:    -2,
+		Autoplay:  true,
+		Hotkeys:   make(map[string]string),
+		path:      path,
+
+		Buffer:         audio.DefaultBuffer,
+		PreloadMB:      16,
+		TrayIcon:       "color",
+		RotateInterval: 30 * time.Minute,
+	}
+	for action, combo := range defaultHotkeys {
+		c.Hotkeys[action] = combo
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Reading the config failed", "err", err)
+		}
+		return c
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		logger.Error("Parsing the config failed", "err", err)
+	}
+	return c
+}
+
+// Save writes the config back to its file. It holds API tokens, so only
+// the user may read it, also when it was written before.
+func (c *Config) Save() {
+	data, err := yaml.Marshal(c)
+	if err == nil {
+		os.MkdirAll(filepath.Dir(c.path), 0o755)
+		err = os.WriteFile(c.path, data, 0o600)
+	}
+	if err == nil {
+		err = os.Chmod(c.path, 0o600)
+	}
+	if err != nil {
+		logger.Error("Saving the config failed", "err", err)
+	}
+}
+
+// SavePreset stores p in the config, replacing a preset of the same name;
+// it reports whether p is new
+func (c *Config) SavePreset(p audio.Preset) bool {
+	defer c.Save()
+	for i := range c.Presets {
+		if c.Presets[i].Name == p.Name {
+			c.Presets[i] = p
+			return false
+		}
+	}
+	c.Presets = append(c.Presets, p)
+	return true
+}
+
+// RecentSounds is how many sounds Recent keeps
+const RecentSounds = 5
+
+// ToggleFavorite pins or unpins a sound, reporting whether it is pinned
+func (c *Config) ToggleFavorite(path string) bool {
+	defer c.Save()
+	for i, sound := range c.Favorites {
+		if sound == path {
+			c.Favorites = append(c.Favorites[:i], c.Favorites[i+1:]...)
+			return false
+		}
+	}
+	c.Favorites = append(c.Favorites, path)
+	return true
+}
+
+// IsFavorite reports whether a sound is pinned
+func (c *Config) IsFavorite(path string) bool {
+	for _, sound := range c.Favorites {
+		if sound == path {
+			return true
+		}
+	}
+	return false
+}
+
+// UseSound records a sound added to the mix: it is loaded on the next
+// start and moves to the front of Recent
+func (c *Config) UseSound(path string) {
+	defer c.Save()
+	c.LastSound = path
+	recent := []string{path}
+	for _, sound := range c.Recent {
+		if sound != path && len(recent) < RecentSounds {
+			recent = append(recent, sound)
+		}
+	}
+	c.Recent = recent
+}
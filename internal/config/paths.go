@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Portable keeps the config, state and caches beside the executable
+// instead of in the user config folder, e.g. to run from a USB stick
+var Portable bool
+
+// AppDataDir returns the per-user folder the app keeps its data in, or
+// the executable's folder in portable mode
+func AppDataDir() string {
+	if Portable {
+		return ExeDir()
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ExeDir()
+	}
+	return filepath.Join(dir, "ambiantgo")
+}
+
+// ExeDir returns the folder the executable is in
+func ExeDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "."
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe)
+}
+
+// ResourcePath finds a file or folder given relative to the install,
+// such as the sounds folder: beside the executable first, then in the
+// app data folder. Portable mode only looks beside the executable.
+// Absolute paths are returned as they are, and a path found nowhere
+// points into the app data folder, where it can be created.
+func ResourcePath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	dirs := []string{ExeDir()}
+	if !Portable {
+		dirs = append(dirs, AppDataDir())
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(AppDataDir(), name)
+}
@@ -0,0 +1,103 @@
+// Package logging writes the app's log: leveled lines tagged with the
+// component they come from, kept in a rotating file in the app data
+// folder so it can be attached to bug reports, and copied to stderr.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/natefinch/lumberjack"
+)
+
+// FileName is the log file in the app data folder; older logs are kept
+// beside it as ambiantgo-<time>.log
+const FileName = "ambiantgo.log"
+
+var (
+	level  slog.LevelVar
+	stderr atomic.Bool
+)
+
+// Setup starts logging to the file in dir, at the info level and to
+// stderr until SetLevel and SetStderr say otherwise. Lines logged with
+// the standard log package end up there too.
+func Setup(dir string) {
+	stderr.Store(true)
+	file := &lumberjack.Logger{
+		Filename:   filepath.Join(dir, FileName),
+		MaxSize:    5, // megabytes
+		MaxBackups: 3,
+	}
+	handler := slog.NewTextHandler(output{file}, &slog.HandlerOptions{Level: &level})
+	slog.SetDefault(slog.New(handler))
+}
+
+// SetLevel sets the least severe level that is logged
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// SetStderr sets whether the log is copied to stderr
+func SetStderr(on bool) {
+	stderr.Store(on)
+}
+
+// ParseLevel parses a level name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", name)
+	}
+	return l, nil
+}
+
+// output writes to the log file and, if asked, to stderr
+type output struct {
+	file io.Writer
+}
+
+func (o output) Write(p []byte) (int, error) {
+	if stderr.Load() {
+		os.Stderr.Write(p)
+	}
+	return o.file.Write(p)
+}
+
+// For returns the logger of a component, e.g. "audio". It can be created
+// before Setup, and logs through whatever Setup set up.
+func For(component string) *slog.Logger {
+	return slog.New(componentHandler{slog.String("component", strings.ToLower(component))})
+}
+
+// componentHandler tags records with their component and hands them to
+// the default logger as it is when they are logged
+type componentHandler struct {
+	component slog.Attr
+}
+
+func (h componentHandler) handler() slog.Handler {
+	return slog.Default().Handler().WithAttrs([]slog.Attr{h.component})
+}
+
+func (h componentHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, l)
+}
+
+func (h componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.handler().WithAttrs(attrs)
+}
+
+func (h componentHandler) WithGroup(name string) slog.Handler {
+	return h.handler().WithGroup(name)
+}
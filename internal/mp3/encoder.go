@@ -0,0 +1,548 @@
+// Package mp3 encodes audio as MPEG-1 Layer III at a constant bitrate.
+// It is a plain encoder: long blocks only, no psychoacoustic model, and
+// each granule quantized with a global gain alone, which is plenty for
+// relaying ambience over a network.
+package mp3
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	granuleSize    = 576
+	frameSize      = 2 * granuleSize
+	sideInfoBytes  = 32
+	maxPart23      = 1<<12 - 1
+	maxQuantized   = 15 + 1<<13 - 1 // the largest value table 31 can code
+	cutoffFreq     = 16000          // content above is dropped to save bits
+	subbands       = 32
+	subbandSamples = 18
+)
+
+// Encoder turns stereo samples into MP3 frames. Every frame carries its
+// own main data, without the bit reservoir, so a listener can start
+// decoding at any frame.
+type Encoder struct {
+	rateIndex    int
+	bitrateIndex int
+	frameBytes   int // bytes in a frame without padding
+	padRest      int // what the frame length falls short of the bitrate by
+	padAcc       int
+	cutoff       int // the first MDCT line that is dropped
+
+	pending [][2]float64
+	history [2][512]float64                      // filterbank input, newest first
+	prev    [2][subbands][subbandSamples]float64 // subband samples of the last granule
+}
+
+// NewEncoder returns an encoder for sampleRate, one of 32000, 44100 or
+// 48000, at a bitrate in kbit/s such as 128
+func NewEncoder(sampleRate, bitrate int) (*Encoder, error) {
+	e := &Encoder{rateIndex: -1, bitrateIndex: -1}
+	for i, r := range sampleRates {
+		if r == sampleRate {
+			e.rateIndex = i
+		}
+	}
+	for i, b := range bitrates {
+		if b == bitrate && i > 0 {
+			e.bitrateIndex = i
+		}
+	}
+	if e.rateIndex < 0 {
+		return nil, fmt.Errorf("mp3: unsupported sample rate %d", sampleRate)
+	}
+	if e.bitrateIndex < 0 {
+		return nil, fmt.Errorf("mp3: unsupported bitrate %d kbit/s", bitrate)
+	}
+
+	e.frameBytes = 144 * bitrate * 1000 / sampleRate
+	e.padRest = 144 * bitrate * 1000 % sampleRate
+	e.cutoff = min(granuleSize, granuleSize*cutoffFreq*2/sampleRate)
+	return e, nil
+}
+
+// Encode takes samples between -1 and 1 and returns the frames they
+// complete; samples short of a frame are kept for the next call
+func (e *Encoder) Encode(samples [][2]float64) []byte {
+	e.pending = append(e.pending, samples...)
+	var out []byte
+	for len(e.pending) >= frameSize {
+		out = e.encodeFrame(out, e.pending[:frameSize])
+		e.pending = e.pending[frameSize:]
+	}
+	// Keep the leftover at the start of the buffer
+	e.pending = append(e.pending[:0:0], e.pending...)
+	return out
+}
+
+// granule is the quantized spectrum of one channel of one granule
+type granule struct {
+	ix         [granuleSize]int
+	globalGain int
+	bigValues  int
+	count1     int // quadruples after the big values
+	tables     [3]int
+	region0    int
+	region1    int
+	count1B    bool // count1 coded with table 33 rather than 32
+	bits       int
+}
+
+func (e *Encoder) encodeFrame(out []byte, samples [][2]float64) []byte {
+	padding := 0
+	e.padAcc += e.padRest
+	if e.padAcc >= sampleRates[e.rateIndex] {
+		e.padAcc -= sampleRates[e.rateIndex]
+		padding = 1
+	}
+	size := e.frameBytes + padding
+	available := (size - 4 - sideInfoBytes) * 8
+
+	var granules [2][2]granule
+	for gr := 0; gr < 2; gr++ {
+		for ch := 0; ch < 2; ch++ {
+			xr := e.spectrum(samples[gr*granuleSize:(gr+1)*granuleSize], ch)
+			// Share what is left of the frame between the granules still
+			// to come
+			budget := min(available/(4-gr*2-ch), maxPart23)
+			quantize(&granules[gr][ch], &xr, &sfbLong[e.rateIndex], budget)
+			available -= granules[gr][ch].bits
+		}
+	}
+
+	w := bitWriter{buf: out}
+	start := len(out)
+	w.write(0x7ff, 11) // sync
+	w.write(3, 2)      // MPEG-1
+	w.write(1, 2)      // Layer III
+	w.write(1, 1)      // no CRC
+	w.write(uint32(e.bitrateIndex), 4)
+	w.write(uint32(e.rateIndex), 2)
+	w.write(uint32(padding), 1)
+	w.write(0, 1) // private
+	w.write(0, 2) // stereo
+	w.write(0, 2) // mode extension
+	w.write(0, 1) // copyright
+	w.write(1, 1) // original
+	w.write(0, 2) // emphasis
+
+	w.write(0, 9) // main_data_begin, no reservoir
+	w.write(0, 3) // private bits
+	w.write(0, 8) // scfsi
+	for gr := 0; gr < 2; gr++ {
+		for ch := 0; ch < 2; ch++ {
+			g := &granules[gr][ch]
+			w.write(uint32(g.bits), 12)
+			w.write(uint32(g.bigValues), 9)
+			w.write(uint32(g.globalGain), 8)
+			w.write(0, 4) // scalefac_compress, no scalefactors
+			w.write(0, 1) // long blocks
+			for _, t := range g.tables {
+				w.write(uint32(t), 5)
+			}
+			w.write(uint32(g.region0), 4)
+			w.write(uint32(g.region1), 3)
+			w.write(0, 1) // preflag
+			w.write(0, 1) // scalefac_scale
+			w.write(boolBit(g.count1B), 1)
+		}
+	}
+
+	for gr := 0; gr < 2; gr++ {
+		for ch := 0; ch < 2; ch++ {
+			e.writeHuffman(&w, &granules[gr][ch])
+		}
+	}
+
+	// The rest of the frame is ancillary data
+	w.flush()
+	for len(w.buf)-start < size {
+		w.buf = append(w.buf, 0)
+	}
+	return w.buf
+}
+
+// spectrum runs one granule of a channel through the polyphase
+// filterbank and the MDCT, returning its 576 frequency lines
+func (e *Encoder) spectrum(samples [][2]float64, ch int) [granuleSize]float64 {
+	var cur [subbands][subbandSamples]float64
+	var sub [subbands]float64
+	for t := 0; t < subbandSamples; t++ {
+		e.analyse(samples[t*subbands:(t+1)*subbands], ch, &sub)
+		for sb := range sub {
+			// Undo the frequency inversion of odd subbands
+			if sb%2 == 1 && t%2 == 1 {
+				sub[sb] = -sub[sb]
+			}
+			cur[sb][t] = sub[sb]
+		}
+	}
+
+	var xr [granuleSize]float64
+	for sb := 0; sb < subbands; sb++ {
+		var z [2 * subbandSamples]float64
+		for k := 0; k < subbandSamples; k++ {
+			z[k] = e.prev[ch][sb][k] * mdctWindow[k]
+			z[k+subbandSamples] = cur[sb][k] * mdctWindow[k+subbandSamples]
+		}
+		for m := 0; m < subbandSamples; m++ {
+			var sum float64
+			for k, v := range z {
+				sum += v * mdctCos[m][k]
+			}
+			xr[sb*subbandSamples+m] = sum / 9
+		}
+	}
+	e.prev[ch] = cur
+
+	// Alias reduction, the inverse of the decoder's butterflies
+	for sb := 1; sb < subbands; sb++ {
+		for i := 0; i < 8; i++ {
+			lo, hi := sb*subbandSamples-1-i, sb*subbandSamples+i
+			l, u := xr[lo], xr[hi]
+			xr[lo] = l*aliasCS[i] + u*aliasCA[i]
+			xr[hi] = u*aliasCS[i] - l*aliasCA[i]
+		}
+	}
+
+	for i := e.cutoff; i < granuleSize; i++ {
+		xr[i] = 0
+	}
+	return xr
+}
+
+// analyse shifts 32 samples of a channel into the filterbank and returns
+// one sample of each subband
+func (e *Encoder) analyse(samples [][2]float64, ch int, sub *[subbands]float64) {
+	x := &e.history[ch]
+	copy(x[subbands:], x[:len(x)-subbands])
+	for i, s := range samples {
+		x[subbands-1-i] = s[ch]
+	}
+
+	var y [64]float64
+	for i := range y {
+		for j := 0; j < 8; j++ {
+			y[i] += float64(analysisWindow[i+64*j]) * x[i+64*j]
+		}
+		y[i] /= 1 << 21
+	}
+	for k := range sub {
+		var sum float64
+		for i, v := range y {
+			sum += filterCos[k][i] * v
+		}
+		sub[k] = sum
+	}
+}
+
+// quantize finds the finest global gain whose spectrum fits in budget bits
+func quantize(g *granule, xr *[granuleSize]float64, sfb *[23]int, budget int) {
+	var peak float64
+	for _, v := range xr {
+		peak = max(peak, math.Abs(v))
+	}
+	if peak == 0 {
+		*g = granule{globalGain: 210}
+		return
+	}
+
+	// The gain where the loudest line still has a code is the finest one
+	// to try
+	lo := int(math.Ceil(210 + 4*math.Log2(peak/math.Pow(maxQuantized, 4.0/3))))
+	lo = max(lo, 0)
+
+	// Quantizing takes each line to the power of 3/4, once for all gains
+	var xr34 [granuleSize]float64
+	for i, v := range xr {
+		xr34[i] = math.Pow(math.Abs(v), 0.75)
+		if v < 0 {
+			xr34[i] = -xr34[i]
+		}
+	}
+	hi := 255
+	for lo < hi {
+		mid := (lo + hi) / 2
+		quantizeAt(g, &xr34, sfb, mid)
+		if g.bits <= budget {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	quantizeAt(g, &xr34, sfb, lo)
+	// Even the coarsest gain can overflow with an impossibly small budget
+	for lo < 255 && g.bits > budget {
+		lo++
+		quantizeAt(g, &xr34, sfb, lo)
+	}
+}
+
+// quantizeAt quantizes a spectrum, raised to the power of 3/4, with a
+// global gain and works out how to code it
+func quantizeAt(g *granule, xr34 *[granuleSize]float64, sfb *[23]int, gain int) {
+	g.globalGain = gain
+	step := math.Pow(2, -float64(gain-210)*3/16)
+	for i, v := range xr34 {
+		q := min(int(math.Abs(v)*step+0.4054), maxQuantized)
+		if v < 0 {
+			q = -q
+		}
+		g.ix[i] = q
+	}
+	g.layout(sfb)
+}
+
+// layout splits the quantized lines into big values, quadruples of ones
+// and zeros and the zeros at the end, picks the tables and counts the bits
+func (g *granule) layout(sfb *[23]int) {
+	end := granuleSize
+	for end > 1 && g.ix[end-1] == 0 && g.ix[end-2] == 0 {
+		end -= 2
+	}
+	g.count1 = 0
+	for end > 3 && abs(g.ix[end-1]) <= 1 && abs(g.ix[end-2]) <= 1 &&
+		abs(g.ix[end-3]) <= 1 && abs(g.ix[end-4]) <= 1 {
+		end -= 4
+		g.count1++
+	}
+	g.bigValues = end / 2
+
+	// Regions of the big values, at scalefactor band boundaries
+	bands := 0
+	for bands < len(sfb)-1 && sfb[bands] < end {
+		bands++
+	}
+	g.region0, g.region1 = subdivisions[bands][0], subdivisions[bands][1]
+	r1 := min(sfb[g.region0+1], end)
+	r2 := min(sfb[g.region0+g.region1+2], end)
+
+	g.bits = 0
+	for i, region := range [][2]int{{0, r1}, {r1, r2}, {r2, end}} {
+		table, n := chooseTable(g.ix[region[0]:region[1]])
+		g.tables[i] = table
+		g.bits += n
+	}
+
+	quads := g.ix[end : end+4*g.count1]
+	a, b := count1Bits(quads, huffTables[32]), count1Bits(quads, huffTables[33])
+	g.count1B = b < a
+	g.bits += min(a, b)
+}
+
+// chooseTable returns the table that codes pairs of lines in the fewest
+// bits, and how many
+func chooseTable(ix []int) (table, n int) {
+	peak := 0
+	for _, v := range ix {
+		peak = max(peak, abs(v))
+	}
+	if peak == 0 {
+		return 0, 0
+	}
+
+	n = math.MaxInt
+	try := func(t int) {
+		if b := pairBits(ix, t); b < n {
+			table, n = t, b
+		}
+	}
+	if peak < 16 {
+		for _, t := range []int{1, 2, 3, 5, 6, 7, 8, 9, 10, 11, 12, 13, 15} {
+			if huffTables[t].xlen > peak {
+				try(t)
+			}
+		}
+	}
+	// Escape tables take the smallest linbits that reach the peak
+	need := bits.Len(uint(max(peak-15, 0)))
+	for _, base := range []int{16, 24} {
+		for t := base; t < base+8; t++ {
+			if linbits[t] >= need {
+				try(t)
+				break
+			}
+		}
+	}
+	return table, n
+}
+
+// codeTable returns the codes a table number uses
+func codeTable(t int) huffTable {
+	switch {
+	case t >= 24:
+		return huffTables[24]
+	case t >= 16:
+		return huffTables[16]
+	}
+	return huffTables[t]
+}
+
+func pairBits(ix []int, t int) int {
+	h, lb := codeTable(t), linbits[t]
+	n := 0
+	for i := 0; i+1 < len(ix); i += 2 {
+		x, y := abs(ix[i]), abs(ix[i+1])
+		if lb > 0 {
+			if x >= 15 {
+				x, n = 15, n+lb
+			}
+			if y >= 15 {
+				y, n = 15, n+lb
+			}
+		}
+		n += int(h.lens[x*h.xlen+y]) + signBits(x) + signBits(y)
+	}
+	return n
+}
+
+func count1Bits(ix []int, h huffTable) int {
+	n := 0
+	for i := 0; i < len(ix); i += 4 {
+		n += int(h.lens[quadIndex(ix[i:i+4])])
+		for _, v := range ix[i : i+4] {
+			n += signBits(v)
+		}
+	}
+	return n
+}
+
+func quadIndex(q []int) int {
+	return abs(q[0])<<3 | abs(q[1])<<2 | abs(q[2])<<1 | abs(q[3])
+}
+
+func (e *Encoder) writeHuffman(w *bitWriter, g *granule) {
+	sfb := sfbLong[e.rateIndex]
+	end := g.bigValues * 2
+	r1 := min(sfb[g.region0+1], end)
+	r2 := min(sfb[g.region0+g.region1+2], end)
+	for i := 0; i < end; i += 2 {
+		t := g.tables[2]
+		switch {
+		case i < r1:
+			t = g.tables[0]
+		case i < r2:
+			t = g.tables[1]
+		}
+		if t == 0 {
+			continue
+		}
+
+		h, lb := codeTable(t), linbits[t]
+		x, y := abs(g.ix[i]), abs(g.ix[i+1])
+		cx, cy := x, y
+		if lb > 0 {
+			cx, cy = min(x, 15), min(y, 15)
+		}
+		w.write(h.codes[cx*h.xlen+cy], int(h.lens[cx*h.xlen+cy]))
+		if lb > 0 && x >= 15 {
+			w.write(uint32(x-15), lb)
+		}
+		if x != 0 {
+			w.write(signBit(g.ix[i]), 1)
+		}
+		if lb > 0 && y >= 15 {
+			w.write(uint32(y-15), lb)
+		}
+		if y != 0 {
+			w.write(signBit(g.ix[i+1]), 1)
+		}
+	}
+
+	h := huffTables[32]
+	if g.count1B {
+		h = huffTables[33]
+	}
+	for i := end; i < end+4*g.count1; i += 4 {
+		q := quadIndex(g.ix[i : i+4])
+		w.write(h.codes[q], int(h.lens[q]))
+		for _, v := range g.ix[i : i+4] {
+			if v != 0 {
+				w.write(signBit(v), 1)
+			}
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func signBits(v int) int {
+	if v != 0 {
+		return 1
+	}
+	return 0
+}
+
+func signBit(v int) uint32 {
+	if v < 0 {
+		return 1
+	}
+	return 0
+}
+
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// bitWriter appends bits to a byte slice, most significant first
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	count int
+}
+
+func (w *bitWriter) write(v uint32, n int) {
+	w.acc = w.acc<<n | uint64(v)&(1<<n-1)
+	w.count += n
+	for w.count >= 8 {
+		w.count -= 8
+		w.buf = append(w.buf, byte(w.acc>>w.count))
+	}
+}
+
+// flush pads the last byte with zeros
+func (w *bitWriter) flush() {
+	if w.count > 0 {
+		w.write(0, 8-w.count)
+	}
+}
+
+var (
+	filterCos  [subbands][64]float64
+	mdctCos    [subbandSamples][2 * subbandSamples]float64
+	mdctWindow [2 * subbandSamples]float64
+
+	// Alias reduction butterflies
+	aliasCS, aliasCA [8]float64
+)
+
+func init() {
+	for k := range filterCos {
+		for i := range filterCos[k] {
+			filterCos[k][i] = math.Cos(float64((2*k+1)*(i-16)) * math.Pi / 64)
+		}
+	}
+	for m := range mdctCos {
+		for k := range mdctCos[m] {
+			mdctCos[m][k] = math.Cos(math.Pi / 72 * float64((2*k+1+18)*(2*m+1)))
+		}
+	}
+	for k := range mdctWindow {
+		mdctWindow[k] = math.Sin(math.Pi / 36 * (float64(k) + 0.5))
+	}
+	for i, c := range []float64{-0.6, -0.535, -0.33, -0.185, -0.095, -0.041, -0.0142, -0.0037} {
+		aliasCS[i] = 1 / math.Sqrt(1+c*c)
+		aliasCA[i] = c / math.Sqrt(1+c*c)
+	}
+}
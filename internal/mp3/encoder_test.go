@@ -0,0 +1,105 @@
+package mp3
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/faiface/beep/mp3"
+)
+
+// tones returns n samples of two tones a side, which no delay lines up
+// with but the right one
+func tones(n, sampleRate int) [][2]float64 {
+	samples := make([][2]float64, n)
+	for i := range samples {
+		t := float64(i) / float64(sampleRate)
+		samples[i] = [2]float64{
+			0.4*math.Sin(2*math.Pi*440*t) + 0.2*math.Sin(2*math.Pi*1234*t),
+			0.3*math.Sin(2*math.Pi*330*t) + 0.3*math.Sin(2*math.Pi*5000*t),
+		}
+	}
+	return samples
+}
+
+func decode(t *testing.T, data []byte) [][2]float64 {
+	t.Helper()
+	s, _, err := mp3.Decode(io.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out [][2]float64
+	buf := make([][2]float64, 4096)
+	for {
+		n, ok := s.Stream(buf)
+		out = append(out, buf[:n]...)
+		if !ok {
+			break
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, rate := range []int{32000, 44100, 48000} {
+		e, err := NewEncoder(rate, 128)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := tones(rate, rate)
+		var data []byte
+		for i := 0; i < len(in); i += 1000 {
+			data = append(data, e.Encode(in[i:min(i+1000, len(in))])...)
+		}
+		// Samples short of a frame are still waiting
+		seconds := float64(len(in)/frameSize*frameSize) / float64(rate)
+		if kbps := float64(len(data)) * 8 / 1000 / seconds; math.Abs(kbps-128) > 1 {
+			t.Errorf("%d Hz: encoded at %.1f kbit/s, want 128", rate, kbps)
+		}
+
+		// The decoded sound lags by the filterbanks of both sides
+		out := decode(t, data)
+		const from, to = 2000, 20000
+		best, lag := math.Inf(1), 0
+		for l := 0; l < 2000; l++ {
+			var d float64
+			for i := from; i < from+2000; i++ {
+				x := out[i+l][0] - in[i][0]
+				d += x * x
+			}
+			if d < best {
+				best, lag = d, l
+			}
+		}
+		for ch := 0; ch < 2; ch++ {
+			var signal, noise float64
+			for i := from; i < to; i++ {
+				d := out[i+lag][ch] - in[i][ch]
+				signal += in[i][ch] * in[i][ch]
+				noise += d * d
+			}
+			if snr := 10 * math.Log10(signal/noise); snr < 40 {
+				t.Errorf("%d Hz: channel %d decodes %.1f dB above the error, want 40 dB", rate, ch, snr)
+			}
+		}
+	}
+}
+
+func TestDecodeFromAnyFrame(t *testing.T) {
+	e, err := NewEncoder(44100, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := e.Encode(tones(frameSize, 44100))
+	rest := e.Encode(tones(10*frameSize, 44100))
+	if len(first) == 0 || len(rest) == 0 {
+		t.Fatal("no frames encoded")
+	}
+	if out := decode(t, rest); len(out) != 10*frameSize {
+		t.Errorf("decoding from the second frame gave %d samples, want %d", len(out), 10*frameSize)
+	}
+}
@@ -0,0 +1,213 @@
+// Package remote lets other programs control the running app, over the
+// HTTP control API and the command line.
+package remote
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+	"rogverse.fyi/ambiantgo/internal/logging"
+)
+
+// logger tags the remote control's log lines
+var logger = logging.For("remote")
+
+// Command is a request from the control API, run by the tray's event
+// loop so the player is only ever touched from one goroutine
+type Command struct {
+	Action string // play, pause, toggle, next, previous, volume, sound, preset, open or activate
+	Value  string
+	Mix    string // for sound: add or remove instead of replacing the mix
+	Reply  chan error
+}
+
+// Control serves the control API, forwarding commands to the loop
+type Control struct {
+	Commands chan Command
+	Status   chan chan audio.Status
+	Events   *eventHub
+	// Token must be sent as a bearer token with every request
+	Token string
+}
+
+func New() *Control {
+	return &Control{
+		Commands: make(chan Command),
+		Status:   make(chan chan audio.Status),
+		Events:   newEventHub(),
+	}
+}
+
+// getStatus asks the event loop for the current state
+func (rc *Control) getStatus() audio.Status {
+	reply := make(chan audio.Status)
+	rc.Status <- reply
+	return <-reply
+}
+
+// Run sends a command to the event loop and waits for it to finish
+func (rc *Control) Run(action, value, mix string) error {
+	reply := make(chan error)
+	rc.Commands <- Command{Action: action, Value: value, Mix: mix, Reply: reply}
+	return <-reply
+}
+
+// commandHeader must be set on every command. Browsers only send a custom
+// header after a CORS preflight, which the API never grants, so a web page
+// can't drive the player even where it can't be told apart by its origin.
+const commandHeader = "X-AmbiantGo"
+
+// NewToken makes a random token for the control API
+func NewToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// authorized reports whether a request carries the API token, in the
+// Authorization header or, for the event stream, which browsers open
+// without headers, in the token query parameter
+func (rc *Control) authorized(req *http.Request) bool {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok && req.URL.Path == "/api/events" {
+		token = req.URL.Query().Get("token")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(rc.Token)) == 1
+}
+
+// ownHost reports whether a request was sent to the API by IP address, as
+// localhost, by the host it listens on or by the machine's name. A DNS
+// rebinding attack reaches the API under the attacker's own name, which is
+// then also its Origin, so Origin only means something once Host is known
+// to be ours.
+func ownHost(req *http.Request, listenHost string) bool {
+	host, _, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		host = req.Host
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil {
+		return true
+	}
+	names := []string{listenHost}
+	if name, err := os.Hostname(); err == nil {
+		names = append(names, name, name+".local")
+	}
+	for _, name := range names {
+		if name != "" && strings.EqualFold(host, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopback reports whether host only accepts connections from this
+// machine; an empty host listens on every interface
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ownOrigin reports whether a request comes from outside a browser, which
+// sends no Origin, or from a page served by the API itself
+func ownOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == req.Host
+}
+
+func (rc *Control) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !ownOrigin(req) {
+		http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+		return
+	}
+
+	action := strings.TrimPrefix(req.URL.Path, "/api/")
+	if action != "status" {
+		if req.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if req.Header.Get(commandHeader) == "" {
+			http.Error(w, "commands need the "+commandHeader+" header", http.StatusForbidden)
+			return
+		}
+
+		q := req.URL.Query()
+		var value string
+		switch action {
+		case "play", "pause", "toggle", "next", "previous":
+		case "volume":
+			value = q.Get("level")
+		case "sound", "preset":
+			value = q.Get("name")
+		default:
+			http.NotFound(w, req)
+			return
+		}
+
+		if err := rc.Run(action, value, q.Get("mix")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rc.getStatus())
+}
+
+// Serve serves the control API on addr in the background. Without a
+// token, addr must be a loopback address.
+func Serve(addr string, rc *Control) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if rc.Token == "" && !isLoopback(host) {
+		return fmt.Errorf("%s is reachable from other machines, use a loopback address such as 127.0.0.1 or set a token", addr)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/events", rc.Events)
+	mux.Handle("/api/", rc)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !ownHost(req, host) {
+			http.Error(w, "unknown host", http.StatusMisdirectedRequest)
+			return
+		}
+		// Checked here so it covers both commands and the event stream
+		if rc.Token != "" && !rc.authorized(req) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "a valid token is needed", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, req)
+	})
+
+	go func() {
+		logger.Info("Control API listening", "url", "http://"+addr+"/api/")
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			logger.Error("Control API stopped", "err", err)
+		}
+	}()
+	return nil
+}
+
+// ErrUnknownCommand is returned for actions the player doesn't know
+var ErrUnknownCommand = errors.New("unknown command")
@@ -0,0 +1,128 @@
+package remote
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// playerEvent is sent to WebSocket clients whenever the state changes
+type playerEvent struct {
+	// Type is "playing", "paused", "volume" or "now_playing"
+	Type  string       `json:"type"`
+	State audio.Status `json:"state"`
+}
+
+// eventHub fans state changes out to connected WebSocket clients
+type eventHub struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	last    audio.Status
+	started bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{clients: make(map[chan []byte]struct{})}
+}
+
+// Publish compares the state with the last one and sends an event for
+// each thing that changed
+func (h *eventHub) Publish(state audio.Status) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prev := h.last
+	h.last = state
+	if !h.started {
+		h.started = true
+		return
+	}
+
+	var types []string
+	if state.Playing != prev.Playing {
+		if state.Playing {
+			types = append(types, "playing")
+		} else {
+			types = append(types, "paused")
+		}
+	}
+	if state.Volume != prev.Volume || state.Muted != prev.Muted {
+		types = append(types, "volume")
+	}
+	if !slices.Equal(state.Sounds, prev.Sounds) {
+		types = append(types, "now_playing")
+	}
+
+	for _, t := range types {
+		data, err := json.Marshal(playerEvent{Type: t, State: state})
+		if err != nil {
+			continue
+		}
+		for ch := range h.clients {
+			// Slow clients miss events rather than hold up the player
+			select {
+			case ch <- data:
+			default:
+			}
+		}
+	}
+}
+
+// Only pages the API would take commands from may listen, so a website
+// can't watch what the user is playing
+var upgrader = websocket.Upgrader{
+	CheckOrigin: ownOrigin,
+}
+
+// ServeHTTP streams events to a client until it disconnects, starting
+// with the current state
+func (h *eventHub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		logger.Error("Opening an event stream failed", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	ch := make(chan []byte, 16)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	hello, _ := json.Marshal(playerEvent{Type: "state", State: h.last})
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, ch)
+		h.mu.Unlock()
+	}()
+
+	// Reading notices when the client goes away
+	closed := make(chan struct{})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteMessage(websocket.TextMessage, hello); err != nil {
+		return
+	}
+	for {
+		select {
+		case data := <-ch:
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
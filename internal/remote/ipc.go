@@ -0,0 +1,199 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// ipcRequest is one command sent by the companion CLI, as a JSON line
+type ipcRequest struct {
+	Action string `json:"action"`
+	Value  string `json:"value,omitempty"`
+	Mix    string `json:"mix,omitempty"`
+}
+
+// ipcResponse answers a request with the resulting state
+type ipcResponse struct {
+	Error string       `json:"error,omitempty"`
+	State audio.Status `json:"state"`
+}
+
+// ErrRunning is returned by ListenIPC when another instance already has
+// the control socket
+var ErrRunning = errors.New("AmbiantGo is already running")
+
+// ListenIPC takes the control socket. Only one instance can have it, so
+// it is also what keeps a second instance from starting.
+func ListenIPC() (net.Listener, error) {
+	if running() {
+		return nil, ErrRunning
+	}
+	l, err := listenIPC()
+	if err != nil && running() {
+		// Another instance was launched at the same time and won
+		return nil, ErrRunning
+	}
+	return l, err
+}
+
+// running reports whether an instance answers on the control socket
+func running() bool {
+	conn, err := dialIPC()
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// ServeIPC accepts commands from the companion CLI and from later
+// launches on the control socket, running them like control API requests
+func ServeIPC(l net.Listener, rc *Control) {
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				logger.Error("Control socket stopped", "err", err)
+				return
+			}
+			go handleIPC(conn, rc)
+		}
+	}()
+}
+
+func handleIPC(conn net.Conn, rc *Control) {
+	defer conn.Close()
+
+	var req ipcRequest
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return
+	}
+	// An instance checking whether one is running sends nothing
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	var resp ipcResponse
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = "invalid request"
+	} else if req.Action != "status" {
+		if err := rc.Run(req.Action, req.Value, req.Mix); err != nil {
+			resp.Error = err.Error()
+		}
+	}
+	resp.State = rc.getStatus()
+
+	json.NewEncoder(conn).Encode(resp)
+}
+
+const CLIUsage = `usage: ambiantgo [flags] <command>
+
+commands:
+  play                 start playing
+  pause                pause
+  toggle               play or pause
+  next, previous       switch to the next or previous sound
+  volume <0-1>         set the volume, e.g. volume 0.5
+  sound <name> [add|remove]
+                       play a sound, or add it to or remove it from the mix
+  preset <name>        play a saved preset
+  open <file>          play a sound file or soundscape folder
+  status               show what is playing`
+
+// RunCLI sends a command to the running instance and prints the result,
+// returning the process exit code
+func RunCLI(args []string) int {
+	req := ipcRequest{Action: args[0]}
+	switch {
+	case req.Action == "play" || req.Action == "pause" || req.Action == "toggle" ||
+		req.Action == "next" || req.Action == "previous" || req.Action == "status":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, CLIUsage)
+			return 2
+		}
+	case req.Action == "volume" && len(args) == 2, req.Action == "preset" && len(args) >= 2:
+		req.Value = strings.Join(args[1:], " ")
+	case req.Action == "open" && len(args) == 2:
+		// The running instance may be in another folder
+		path, err := filepath.Abs(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		req.Value = path
+	case req.Action == "sound" && (len(args) == 2 || len(args) == 3):
+		req.Value = args[1]
+		if len(args) == 3 {
+			req.Mix = args[2]
+		}
+	default:
+		fmt.Fprintln(os.Stderr, CLIUsage)
+		return 2
+	}
+
+	resp, err := sendIPC(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Println(resp.State.NowPlaying)
+	fmt.Printf("Volume: %.0f%%\n", resp.State.Volume*100)
+	return 0
+}
+
+// IsCommand reports whether a command line argument is a CLI command
+// rather than something to open
+func IsCommand(arg string) bool {
+	switch arg {
+	case "play", "pause", "toggle", "next", "previous", "volume", "sound", "preset", "open", "status":
+		return true
+	}
+	return false
+}
+
+// Activate hands a second launch over to the running instance, which
+// plays the sound file or folder at path, or with no path shows that it
+// is already running. It returns the process exit code.
+func Activate(path string) int {
+	req := ipcRequest{Action: "activate"}
+	if path != "" {
+		req = ipcRequest{Action: "open", Value: path}
+	}
+	if _, err := sendIPC(req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// sendIPC sends a request to the running instance and returns its reply
+func sendIPC(req ipcRequest) (ipcResponse, error) {
+	var resp ipcResponse
+	conn, err := dialIPC()
+	if err != nil {
+		return resp, fmt.Errorf("AmbiantGo is not running: %w", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, fmt.Errorf("sending command: %w", err)
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("reading reply: %w", err)
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,44 @@
+//go:build !windows
+
+package remote
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ipcSocket is the Unix socket the companion CLI connects to, in the
+// per-user runtime folder when there is one
+func ipcSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	// The user ID keeps users sharing a temp folder apart
+	return filepath.Join(dir, "ambiantgo-"+strconv.Itoa(os.Getuid())+".sock")
+}
+
+// listenIPC opens the control socket, replacing one left behind by an
+// instance that didn't shut down cleanly
+func listenIPC() (net.Listener, error) {
+	path := ipcSocket()
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+	} else {
+		os.Remove(path)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	os.Chmod(path, 0o600)
+	return l, nil
+}
+
+// dialIPC connects to the running instance
+func dialIPC() (net.Conn, error) {
+	return net.Dial("unix", ipcSocket())
+}
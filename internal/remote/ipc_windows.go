@@ -0,0 +1,44 @@
+package remote
+
+import (
+	"net"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+// userSID returns the security ID of the signed-in user
+func userSID() (string, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", err
+	}
+	return user.User.Sid.String(), nil
+}
+
+// ipcPipe is the named pipe the companion CLI connects to. Pipes are
+// shared by the whole machine, so the user's SID keeps each user's
+// instance apart.
+func ipcPipe(sid string) string {
+	return `\\.\pipe\ambiantgo-` + sid
+}
+
+// listenIPC opens the named pipe, which only the user may connect to
+func listenIPC() (net.Listener, error) {
+	sid, err := userSID()
+	if err != nil {
+		return nil, err
+	}
+	return winio.ListenPipe(ipcPipe(sid), &winio.PipeConfig{
+		SecurityDescriptor: "D:P(A;;GA;;;" + sid + ")",
+	})
+}
+
+// dialIPC connects to the running instance
+func dialIPC() (net.Conn, error) {
+	sid, err := userSID()
+	if err != nil {
+		return nil, err
+	}
+	return winio.DialPipe(ipcPipe(sid), nil)
+}
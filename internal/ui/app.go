@@ -0,0 +1,304 @@
+// Package ui is the desktop side of the app: the tray menu, hotkeys,
+// media controls and notices, and the event loop that drives the player.
+package ui
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+	"rogverse.fyi/ambiantgo/internal/config"
+	"rogverse.fyi/ambiantgo/internal/logging"
+	"rogverse.fyi/ambiantgo/internal/remote"
+)
+
+// logger tags the log lines of the tray and everything around it
+var logger = logging.For("ui")
+
+// App drives the player from everything around it: the tray or the
+// headless loop, hotkeys, timers, device changes and the remote control.
+// Its methods run on the event loop, which owns the player.
+type App struct {
+	Player  *audio.Player
+	Config  *config.Config
+	Remote  *remote.Control
+	Profile audio.VolumeProfile
+	Guard   *audio.ListeningGuard
+	Output  *audio.OutputPolicy
+	Volumes *audio.DeviceVolumes
+	Rotate  *audio.Rotation
+	Away    *AwayPolicy
+
+	Schedule    []audio.ScheduleWindow
+	StatePath   string
+	SleepCustom time.Duration // extra sleep timer length offered in the tray
+	MediaKeys   bool          // use the keyboard media keys
+	Icons       fs.FS         // the tray icons, ambiantgo.ico and icons/
+
+	// Notify shows a notice to the user
+	Notify func(title, message string)
+	// UpdateMediaSession shows the player in the desktop media controls
+	UpdateMediaSession func(audio.Status)
+
+	// onChange shows the state in the tray, if there is one
+	onChange func(audio.Status)
+
+	minuteTick      <-chan time.Time
+	scheduleChecked time.Time // when the schedule was last run
+	libraryChanged  <-chan audio.LibraryChange
+	deviceChanged   <-chan audio.DeviceChange
+	hotkeyPressed   <-chan string
+	mediaKeyPressed <-chan string
+	sessionChanged  <-chan sessionEvent
+}
+
+// Watch starts the watchers the event loop listens to
+func (a *App) Watch() {
+	// Sounds added to or deleted from the folder update the library live
+	a.libraryChanged = audio.WatchSounds(a.Player.SoundsDir)
+
+	// Once a minute, check whether the day or night profile applies
+	// and how long playback has been loud
+	a.minuteTick = time.Tick(time.Minute)
+	// A window already open at launch starts playing now
+	a.runSchedule(time.Now())
+
+	a.deviceChanged = audio.WatchDefaultDevice(500 * time.Millisecond)
+
+	// Media keys are opt-in, on Windows they stop reaching other players
+	hotkeys := hotkeyBindings(a.Config.Hotkeys)
+	if a.MediaKeys {
+		hotkeys = append(hotkeys, mediaKeyBindings()...)
+		a.mediaKeyPressed = watchMediaKeys()
+	}
+	a.hotkeyPressed = watchHotkeys(hotkeys)
+
+	if a.Away.Pause {
+		a.sessionChanged = watchSession()
+	}
+}
+
+func (a *App) onMinute(now time.Time) {
+	a.Player.SetBaseline(a.Profile.Baseline(now))
+	a.runSchedule(now)
+	if a.Guard.Check(a.Player, now) {
+		a.Notify("AmbiantGo", a.Guard.Message())
+	}
+}
+
+// runSchedule acts on the schedule windows that opened or closed since
+// it last ran
+func (a *App) runSchedule(now time.Time) {
+	// Asking every minute must not hold playback the user paused
+	if a.Player.RunSchedule(a.Schedule, a.scheduleChecked, now, a.Output.Allowed()) {
+		a.Output.Held = true
+	}
+	a.scheduleChecked = now
+}
+
+func (a *App) onSessionEvent(event sessionEvent) {
+	if a.Away.handle(a.Player, event, a.Output.Allowed()) {
+		a.Output.Held = true
+	}
+}
+
+func (a *App) onDeviceChange(change audio.DeviceChange) {
+	a.Output.Handle(a.Player, change)
+	if volume, ok := a.Volumes.SwitchTo(change.ID); ok {
+		a.Player.SetVolume(volume)
+	}
+}
+
+// setVolume changes the volume picked by the user, remembering it for
+// the current device and as the default; it also unmutes
+func (a *App) setVolume(volume float64) {
+	a.Player.SetMuted(false)
+	a.Player.SetVolume(volume)
+	a.Volumes.Remember(a.Player.Volume)
+	a.Config.Volume = a.Player.Volume
+	a.Config.Save()
+}
+
+func (a *App) onHotkey(action string) {
+	switch action {
+	case hotkeyToggle:
+		if a.Player.IsPlaying() {
+			a.Output.Held = false
+			a.Player.Pause()
+		} else if a.Output.CanPlay() {
+			a.Player.Play()
+		}
+	case hotkeyPause:
+		a.Output.Held = false
+		a.Player.Pause()
+	case hotkeyNext, hotkeyPrevious:
+		step := 1
+		if action == hotkeyPrevious {
+			step = -1
+		}
+		if next := a.Player.AdjacentSound(step); next != "" {
+			a.Player.SelectSound(next)
+		}
+	case hotkeyVolumeUp, hotkeyVolumeDown:
+		volume := a.Player.Volume + volumeStep
+		if action == hotkeyVolumeDown {
+			volume = a.Player.Volume - volumeStep
+		}
+		a.setVolume(math.Max(audio.MinVolume, math.Min(0, volume)))
+	case hotkeyMute:
+		a.Player.SetMuted(!a.Player.Muted)
+	}
+}
+
+// runRemote carries out a command from the control API
+func (a *App) runRemote(cmd remote.Command) error {
+	switch cmd.Action {
+	case "play":
+		if !a.Output.CanPlay() {
+			return fmt.Errorf("playback is held until headphones are the output")
+		}
+		if a.Player.IsPlaying() {
+			return nil
+		}
+		return a.Player.Play()
+	case "pause":
+		a.Output.Held = false
+		a.Player.Pause()
+	case "toggle":
+		a.onHotkey(hotkeyToggle)
+	case "next":
+		a.onHotkey(hotkeyNext)
+	case "previous":
+		a.onHotkey(hotkeyPrevious)
+	case "volume":
+		volume, err := audio.ParseVolumeFraction(cmd.Value)
+		if err != nil {
+			return err
+		}
+		a.setVolume(volume)
+	case "sound":
+		path, ok := a.Player.FindSound(cmd.Value)
+		if !ok {
+			return fmt.Errorf("no sound named %q", cmd.Value)
+		}
+		switch cmd.Mix {
+		case "add":
+			if err := a.Player.AddSound(path); err != nil {
+				return err
+			}
+		case "remove":
+			a.Player.RemoveSound(path)
+		case "":
+			a.Player.SelectSound(path)
+		default:
+			return fmt.Errorf("invalid mix %q, expected add or remove", cmd.Mix)
+		}
+		if a.Player.Channel(path) != nil {
+			a.Config.UseSound(path)
+		}
+	case "open":
+		return a.open(cmd.Value)
+	case "activate":
+		a.Notify("AmbiantGo", "AmbiantGo is already running, its menu is in the tray.")
+	case "preset":
+		for _, p := range a.Config.Presets {
+			if strings.EqualFold(p.Name, cmd.Value) {
+				a.Player.ApplyPreset(p)
+				a.Volumes.Remember(a.Player.Volume)
+				return nil
+			}
+		}
+		return fmt.Errorf("no preset named %q", cmd.Value)
+	default:
+		return remote.ErrUnknownCommand
+	}
+	return nil
+}
+
+// open plays a sound file or soundscape folder handed over by another
+// launch, in place of the mix. It plays from where it is, without being
+// added to the library.
+func (a *App) open(path string) error {
+	if strings.EqualFold(filepath.Ext(path), audio.PackExt) {
+		return fmt.Errorf("sound packs are imported from the Presets menu")
+	}
+	a.Player.SelectSound(path)
+	if a.Player.Channel(path) == nil {
+		return fmt.Errorf("%s could not be played", audio.SoundName(path))
+	}
+	if a.Player.IsPlaying() || !a.Output.CanPlay() {
+		return nil
+	}
+	return a.Player.Play()
+}
+
+// publish sends the player's state to API clients, the media controls
+// and the tray
+func (a *App) publish() {
+	if errs := a.Player.LoadErrors(); len(errs) > 0 {
+		a.Notify("AmbiantGo", audio.LoadErrorsMessage(errs))
+	}
+
+	status := a.Player.Status()
+	a.Remote.Events.Publish(status)
+	a.UpdateMediaSession(status)
+	if a.onChange != nil {
+		a.onChange(status)
+	}
+}
+
+// cleanup saves the state and releases the speaker before quitting
+func (a *App) cleanup() {
+	a.Player.SaveState(a.StatePath)
+	a.Player.Close()
+}
+
+// RunHeadless runs the event loop without a tray icon, driven by the CLI
+// and control API only, until the process is interrupted
+func (a *App) RunHeadless() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	a.publish()
+	for {
+		select {
+		case cmd := <-a.Remote.Commands:
+			cmd.Reply <- a.runRemote(cmd)
+		case reply := <-a.Remote.Status:
+			reply <- a.Player.Status()
+		case now := <-a.minuteTick:
+			a.onMinute(now)
+		case <-a.Rotate.C:
+			a.Rotate.Rotate(a.Player)
+		case <-a.Player.Changed():
+		case path := <-a.Player.Analysed():
+			a.Player.ApplyAnalysis(path)
+		case errs := <-a.Player.Unplayable():
+			a.Player.DropUnplayable(errs)
+		case change := <-a.deviceChanged:
+			a.onDeviceChange(change)
+		case action := <-a.hotkeyPressed:
+			a.onHotkey(action)
+		case action := <-a.mediaKeyPressed:
+			a.onHotkey(action)
+		case event := <-a.sessionChanged:
+			a.onSessionEvent(event)
+		case change := <-a.libraryChanged:
+			if change.Removed {
+				a.Player.RemoveFromLibrary(change.Path)
+			} else {
+				a.Player.AddToLibrary(change.Path)
+			}
+		case <-stop:
+			a.cleanup()
+			return
+		}
+		a.publish()
+	}
+}
@@ -0,0 +1,140 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/getlantern/systray"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// catalogMenu is the "Get more sounds" submenu. The catalog is only
+// fetched when asked for; downloads run in the background and report to
+// the event loop, and the sounds folder watcher lists the new files.
+type catalogMenu struct {
+	parent *systray.MenuItem
+	load   *systray.MenuItem
+	items  map[string]*systray.MenuItem
+	packs  map[string]audio.CatalogPack // as last fetched
+	busy   map[string]bool              // packs being downloaded
+
+	loaded   chan catalogResult
+	clicked  chan string
+	progress chan packProgress
+}
+
+// catalogResult is a fetched catalog, or why it couldn't be fetched
+type catalogResult struct {
+	catalog *audio.Catalog
+	err     error
+}
+
+// packProgress reports on a pack being downloaded
+type packProgress struct {
+	pack        audio.CatalogPack
+	done, total int64
+	finished    bool
+	err         error
+}
+
+// newCatalogMenu adds the submenu, hidden when no catalog is configured
+func newCatalogMenu(url string) *catalogMenu {
+	m := &catalogMenu{
+		parent:   systray.AddMenuItem("Get more sounds", "Download sound packs"),
+		items:    make(map[string]*systray.MenuItem),
+		packs:    make(map[string]audio.CatalogPack),
+		busy:     make(map[string]bool),
+		loaded:   make(chan catalogResult),
+		clicked:  make(chan string),
+		progress: make(chan packProgress),
+	}
+	m.load = m.parent.AddSubMenuItem("Load catalog", "Fetch the list of sound packs")
+	if url == "" {
+		m.parent.Hide()
+	}
+	return m
+}
+
+// fetch downloads the catalog in the background
+func (m *catalogMenu) fetch(url string) {
+	m.load.SetTitle("Loading catalog...")
+	m.load.Disable()
+	go func() {
+		c, err := audio.FetchCatalog(url)
+		m.loaded <- catalogResult{c, err}
+	}()
+}
+
+// show lists the packs of a fetched catalog, reusing the items of packs
+// listed before
+func (m *catalogMenu) show(r catalogResult, dir string, notify func(title, message string)) {
+	m.load.SetTitle("Reload catalog")
+	m.load.Enable()
+	if r.err != nil {
+		logger.Error("Loading the sound catalog failed", "err", r.err)
+		notify("AmbiantGo", "The sound catalog could not be loaded.")
+		return
+	}
+
+	for _, pack := range r.catalog.Packs {
+		m.packs[pack.Name] = pack
+		item, ok := m.items[pack.Name]
+		if !ok {
+			item = m.parent.AddSubMenuItem(pack.Name, pack.Description)
+			m.items[pack.Name] = item
+			go func(name string) {
+				for {
+					<-item.ClickedCh
+					m.clicked <- name
+				}
+			}(pack.Name)
+		}
+		item.SetTooltip(pack.Description)
+		if m.busy[pack.Name] {
+			continue
+		}
+		if pack.Installed(dir) {
+			item.SetTitle(pack.Name + " (installed)")
+			item.Disable()
+		} else {
+			item.SetTitle(fmt.Sprintf("%s (%.0f MB)", pack.Name, float64(pack.Size())/(1<<20)))
+			item.Enable()
+		}
+	}
+}
+
+// download fetches a pack into dir in the background
+func (m *catalogMenu) download(name, dir string) {
+	pack := m.packs[name]
+	if m.busy[name] {
+		return
+	}
+	m.busy[name] = true
+	m.items[name].SetTitle(name + " (0%)")
+	m.items[name].Disable()
+	go func() {
+		err := pack.Download(dir, func(done, total int64) {
+			m.progress <- packProgress{pack: pack, done: done, total: total}
+		})
+		m.progress <- packProgress{pack: pack, finished: true, err: err}
+	}()
+}
+
+// update shows how far a download is
+func (m *catalogMenu) update(p packProgress, notify func(title, message string)) {
+	item := m.items[p.pack.Name]
+	switch {
+	case !p.finished:
+		item.SetTitle(fmt.Sprintf("%s (%d%%)", p.pack.Name, p.done*100/p.total))
+	case p.err != nil:
+		delete(m.busy, p.pack.Name)
+		logger.Error("Downloading a sound pack failed", "pack", p.pack.Name, "err", p.err)
+		item.SetTitle(p.pack.Name + " (failed, click to retry)")
+		item.Enable()
+		notify("AmbiantGo", "Downloading "+p.pack.Name+" failed.")
+	default:
+		delete(m.busy, p.pack.Name)
+		item.SetTitle(p.pack.Name + " (installed)")
+		notify("AmbiantGo", p.pack.Name+" has been added to the Sounds menu.")
+	}
+}
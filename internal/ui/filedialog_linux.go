@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// pickFile asks for a file with the extension ext (e.g. ".ambipack") to
+// open, or to save when save is set. It blocks until the dialog is
+// closed and reports false if it was cancelled or no dialog tool is
+// installed.
+func pickFile(title, ext string, save bool) (string, bool) {
+	args := []string{"--file-selection", "--title", title, "--file-filter", "Sound packs | *" + ext}
+	if save {
+		args = append(args, "--save", "--confirm-overwrite")
+	}
+	cmd := exec.Command("zenity", args...)
+	if _, err := exec.LookPath("zenity"); err != nil {
+		mode := "--getopenfilename"
+		if save {
+			mode = "--getsavefilename"
+		}
+		cmd = exec.Command("kdialog", "--title", title, mode, ".", "*"+ext)
+	}
+	out, err := cmd.Output()
+	path := strings.TrimSpace(string(out))
+	if err != nil || path == "" {
+		return "", false
+	}
+	if save && !strings.HasSuffix(path, ext) {
+		path += ext
+	}
+	return path, true
+}
@@ -0,0 +1,28 @@
+//go:build !windows && !linux
+
+package ui
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// pickFile asks for a file with the extension ext (e.g. ".ambipack") to
+// open, or to save when save is set. It blocks until the dialog is
+// closed and reports false if it was cancelled.
+func pickFile(title, ext string, save bool) (string, bool) {
+	script := "POSIX path of (choose file with prompt " + strconv.Quote(title) + ")"
+	if save {
+		script = "POSIX path of (choose file name with prompt " + strconv.Quote(title) + ")"
+	}
+	out, err := exec.Command("osascript", "-e", script).Output()
+	path := strings.TrimSpace(string(out))
+	if err != nil || path == "" {
+		return "", false
+	}
+	if save && !strings.HasSuffix(path, ext) {
+		path += ext
+	}
+	return path, true
+}
@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	comdlg32            = syscall.NewLazyDLL("comdlg32.dll")
+	procGetOpenFileName = comdlg32.NewProc("GetOpenFileNameW")
+	procGetSaveFileName = comdlg32.NewProc("GetSaveFileNameW")
+)
+
+const (
+	ofnOverwritePrompt = 0x2
+	ofnNoChangeDir     = 0x8
+	ofnPathMustExist   = 0x800
+	ofnFileMustExist   = 0x1000
+	ofnExplorer        = 0x80000
+)
+
+// openFileName is OPENFILENAMEW
+type openFileName struct {
+	structSize    uint32
+	owner         uintptr
+	instance      uintptr
+	filter        *uint16
+	customFilter  *uint16
+	maxCustFilter uint32
+	filterIndex   uint32
+	file          *uint16
+	maxFile       uint32
+	fileTitle     *uint16
+	maxFileTitle  uint32
+	initialDir    *uint16
+	title         *uint16
+	flags         uint32
+	fileOffset    uint16
+	fileExtension uint16
+	defExt        *uint16
+	custData      uintptr
+	hook          uintptr
+	templateName  *uint16
+	reserved      uintptr
+	reservedInt   uint32
+	flagsEx       uint32
+}
+
+// pickFile asks for a file with the extension ext (e.g. ".ambipack") to
+// open, or to save when save is set. It blocks until the dialog is
+// closed and reports false if it was cancelled.
+func pickFile(title, ext string, save bool) (string, bool) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// The filter is pairs of NUL terminated strings, ending with a NUL
+	pattern := "*" + ext
+	filter := utf16Ptr(strings.Join([]string{"Sound packs (" + pattern + ")", pattern, "All files", "*.*", ""}, "\x00"))
+	file := make([]uint16, syscall.MAX_PATH)
+	ofn := openFileName{
+		filter:  filter,
+		file:    &file[0],
+		maxFile: uint32(len(file)),
+		title:   utf16Ptr(title),
+		defExt:  utf16Ptr(strings.TrimPrefix(ext, ".")),
+		flags:   ofnExplorer | ofnNoChangeDir | ofnPathMustExist,
+	}
+	ofn.structSize = uint32(unsafe.Sizeof(ofn))
+
+	proc := procGetOpenFileName
+	if save {
+		proc = procGetSaveFileName
+		ofn.flags |= ofnOverwritePrompt
+	} else {
+		ofn.flags |= ofnFileMustExist
+	}
+	if ok, _, _ := proc.Call(uintptr(unsafe.Pointer(&ofn))); ok == 0 {
+		return "", false
+	}
+	return syscall.UTF16ToString(file), true
+}
+
+func utf16Ptr(s string) *uint16 {
+	p, _ := syscall.UTF16PtrFromString(s)
+	return p
+}
@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"github.com/getlantern/systray"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// freesoundMenu is the Freesound submenu: a search, then one item per
+// result to preview it or add it to the library. systray can't remove
+// items, so a fixed number of result slots is reused by every search.
+type freesoundMenu struct {
+	parent  *systray.MenuItem
+	search  *systray.MenuItem
+	slots   []freesoundSlot
+	results []audio.FreesoundSound
+
+	found     chan freesoundResults
+	preview   chan int // slot picked to listen to
+	add       chan int // slot picked to download
+	previewed chan []byte
+	added     chan freesoundAdded
+}
+
+// freesoundSlot holds the items of one search result
+type freesoundSlot struct {
+	parent, preview, add *systray.MenuItem
+}
+
+// freesoundResults is what a search found, or why it failed
+type freesoundResults struct {
+	query  string
+	sounds []audio.FreesoundSound
+	err    error
+}
+
+// freesoundAdded reports a finished download
+type freesoundAdded struct {
+	sound audio.FreesoundSound
+	err   error
+}
+
+// newFreesoundMenu adds the submenu, hidden without an API key
+func newFreesoundMenu(token string) *freesoundMenu {
+	m := &freesoundMenu{
+		parent:    systray.AddMenuItem("Freesound", "Find sounds on freesound.org"),
+		found:     make(chan freesoundResults),
+		preview:   make(chan int),
+		add:       make(chan int),
+		previewed: make(chan []byte),
+		added:     make(chan freesoundAdded),
+	}
+	m.search = m.parent.AddSubMenuItem("Search...", "Search freesound.org for Creative Commons sounds")
+	for i := 0; i < audio.FreesoundResults; i++ {
+		slot := freesoundSlot{parent: m.parent.AddSubMenuItem("", "")}
+		slot.preview = slot.parent.AddSubMenuItem("Preview", "Listen to this sound")
+		slot.add = slot.parent.AddSubMenuItem("Add to library", "Download this sound into the sounds folder")
+		slot.parent.Hide()
+		m.slots = append(m.slots, slot)
+		go func(i int) {
+			for {
+				select {
+				case <-slot.preview.ClickedCh:
+					m.preview <- i
+				case <-slot.add.ClickedCh:
+					m.add <- i
+				}
+			}
+		}(i)
+	}
+	if token == "" {
+		m.parent.Hide()
+	}
+	return m
+}
+
+// ask prompts for a search and runs it in the background
+func (m *freesoundMenu) ask(token string) {
+	go func() {
+		query, ok := promptText("Freesound", "Search for sounds, e.g. thunderstorm", "")
+		if !ok {
+			return
+		}
+		m.search.SetTitle("Searching...")
+		sounds, err := audio.SearchFreesound(token, query)
+		m.found <- freesoundResults{query, sounds, err}
+	}()
+}
+
+// show fills the result slots with what a search found
+func (m *freesoundMenu) show(r freesoundResults, notify func(title, message string)) {
+	m.search.SetTitle("Search...")
+	if r.err != nil {
+		logger.Error("Searching Freesound failed", "query", r.query, "err", r.err)
+		notify("AmbiantGo", "Searching Freesound failed.")
+		return
+	}
+	if len(r.sounds) == 0 {
+		notify("AmbiantGo", "Nothing found on Freesound for \""+r.query+"\".")
+	}
+
+	m.results = r.sounds
+	for i, slot := range m.slots {
+		if i >= len(r.sounds) {
+			slot.parent.Hide()
+			continue
+		}
+		slot.parent.SetTitle(r.sounds[i].Title())
+		slot.parent.SetTooltip(r.sounds[i].License)
+		slot.add.SetTitle("Add to library")
+		slot.add.Enable()
+		slot.parent.Show()
+	}
+}
+
+// listen downloads a result's preview in the background
+func (m *freesoundMenu) listen(i int) {
+	sound := m.results[i]
+	go func() {
+		data, err := sound.Preview()
+		if err != nil {
+			logger.Error("Downloading a Freesound preview failed", "sound", sound.ID, "err", err)
+			return
+		}
+		m.previewed <- data
+	}()
+}
+
+// download adds a result to the library in the background; the sounds
+// folder watcher lists it once it is in
+func (m *freesoundMenu) download(i int, dir string) {
+	sound := m.results[i]
+	m.slots[i].add.SetTitle("Adding...")
+	m.slots[i].add.Disable()
+	go func() {
+		_, err := sound.Download(dir)
+		m.added <- freesoundAdded{sound, err}
+	}()
+}
+
+// done marks a finished download
+func (m *freesoundMenu) done(r freesoundAdded, notify func(title, message string)) {
+	for i, s := range m.results {
+		if s.ID != r.sound.ID {
+			continue
+		}
+		if r.err != nil {
+			m.slots[i].add.SetTitle("Add to library")
+			m.slots[i].add.Enable()
+		} else {
+			m.slots[i].add.SetTitle("Added")
+		}
+	}
+	if r.err != nil {
+		logger.Error("Downloading from Freesound failed", "sound", r.sound.ID, "err", r.err)
+		notify("AmbiantGo", "Adding "+r.sound.Name+" failed.")
+	}
+}
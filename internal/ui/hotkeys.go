@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Hotkey actions, as used in the hotkeys section of the config file
+const (
+	hotkeyToggle     = "toggle"
+	hotkeyVolumeUp   = "volume_up"
+	hotkeyVolumeDown = "volume_down"
+	hotkeyMute       = "mute"
+	hotkeyPause      = "pause"
+	hotkeyNext       = "next"
+	hotkeyPrevious   = "previous"
+)
+
+// hotkeyBinding is a key combination and the action it triggers
+type hotkeyBinding struct {
+	action string
+	combo  string
+}
+
+// volumeStep is how much a volume hotkey changes the volume, on the log2
+// scale of the player
+const volumeStep = 0.5
+
+// splitHotkey splits a combination such as "Ctrl+Alt+Up" into its
+// lowercase modifiers and key
+func splitHotkey(combo string) (mods []string, key string, err error) {
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(combo, " ", "")), "+")
+	key = parts[len(parts)-1]
+	if key == "" {
+		return nil, "", fmt.Errorf("invalid hotkey %q, expected e.g. Ctrl+Alt+A", combo)
+	}
+	for _, mod := range parts[:len(parts)-1] {
+		switch mod {
+		case "ctrl", "control":
+			mods = append(mods, "ctrl")
+		case "alt", "shift":
+			mods = append(mods, mod)
+		case "win", "super", "cmd":
+			mods = append(mods, "win")
+		default:
+			return nil, "", fmt.Errorf("invalid modifier %q in hotkey %q", mod, combo)
+		}
+	}
+	return mods, key, nil
+}
+
+// hotkeyBindings lists the enabled hotkeys of the config in a stable order
+func hotkeyBindings(hotkeys map[string]string) []hotkeyBinding {
+	var bindings []hotkeyBinding
+	for action, combo := range hotkeys {
+		if combo != "" {
+			bindings = append(bindings, hotkeyBinding{action, combo})
+		}
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].action < bindings[j].action
+	})
+	return bindings
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package ui
+
+// watchHotkeys returns nil; global hotkeys are only registered on Windows
+func watchHotkeys(bindings []hotkeyBinding) <-chan string {
+	return nil
+}
@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"unsafe"
+
+	"rogverse.fyi/ambiantgo/internal/winapi"
+)
+
+var (
+	procRegisterHotKey = winapi.User32.NewProc("RegisterHotKey")
+	procGetMessage     = winapi.User32.NewProc("GetMessageW")
+)
+
+const (
+	modAlt      = 0x1
+	modControl  = 0x2
+	modShift    = 0x4
+	modWin      = 0x8
+	modNoRepeat = 0x4000
+	wmHotkey    = 0x0312
+)
+
+// virtualKeys maps key names to Windows virtual-key codes; letters and
+// digits map to their own character
+var virtualKeys = map[string]uintptr{
+	"space": 0x20, "pageup": 0x21, "pagedown": 0x22, "end": 0x23, "home": 0x24,
+	"left": 0x25, "up": 0x26, "right": 0x27, "down": 0x28,
+	"insert": 0x2D, "delete": 0x2E,
+	"medianext": 0xB0, "mediaprevious": 0xB1, "mediastop": 0xB2, "mediaplaypause": 0xB3,
+}
+
+type winMsg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      struct{ x, y int32 }
+}
+
+// parseHotkey converts a combination such as "Ctrl+Alt+A" to the
+// modifiers and virtual-key code RegisterHotKey takes
+func parseHotkey(combo string) (mods, vk uintptr, err error) {
+	names, key, err := splitHotkey(combo)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, name := range names {
+		switch name {
+		case "ctrl":
+			mods |= modControl
+		case "alt":
+			mods |= modAlt
+		case "shift":
+			mods |= modShift
+		case "win":
+			mods |= modWin
+		}
+	}
+
+	switch {
+	case len(key) == 1 && (key[0] >= 'a' && key[0] <= 'z' || key[0] >= '0' && key[0] <= '9'):
+		vk = uintptr(key[0])
+		if key[0] >= 'a' {
+			vk -= 'a' - 'A'
+		}
+	case len(key) > 1 && key[0] == 'f':
+		n, err := strconv.Atoi(key[1:])
+		if err != nil || n < 1 || n > 24 {
+			return 0, 0, fmt.Errorf("invalid key %q in hotkey %q", key, combo)
+		}
+		vk = 0x70 + uintptr(n-1)
+	default:
+		var ok bool
+		if vk, ok = virtualKeys[key]; !ok {
+			return 0, 0, fmt.Errorf("invalid key %q in hotkey %q", key, combo)
+		}
+	}
+	return mods, vk, nil
+}
+
+// watchHotkeys registers the hotkeys system wide and reports the action of
+// each one pressed on the returned channel
+func watchHotkeys(bindings []hotkeyBinding) <-chan string {
+	pressed := make(chan string, 4)
+
+	go func() {
+		// Hotkey messages are posted to the thread that registered them
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		var ids []string
+		for _, b := range bindings {
+			mods, vk, err := parseHotkey(b.combo)
+			if err != nil {
+				logger.Warn("Ignoring hotkey", "action", b.action, "err", err)
+				continue
+			}
+			if r, _, err := procRegisterHotKey.Call(0, uintptr(len(ids)), mods|modNoRepeat, vk); r == 0 {
+				logger.Error("Registering a hotkey failed", "hotkey", b.combo, "err", err)
+				continue
+			}
+			ids = append(ids, b.action)
+		}
+		if len(ids) == 0 {
+			return
+		}
+
+		var m winMsg
+		for {
+			r, _, _ := procGetMessage.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+			if int32(r) <= 0 {
+				return
+			}
+			if m.message == wmHotkey && int(m.wParam) < len(ids) {
+				select {
+				case pressed <- ids[m.wParam]:
+				default:
+				}
+			}
+		}
+	}()
+
+	return pressed
+}
@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"io/fs"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// trayIcons are the tray icons for playing and paused in one style
+type trayIcons struct {
+	playing, paused []byte
+	shown           bool // whether playing is the icon shown
+	set             bool // whether an icon has been shown yet
+}
+
+// loadTrayIcons reads the icons of a style: color, mono to match the
+// taskbar, white for dark taskbars or black for light ones
+func loadTrayIcons(icons fs.FS, style string) *trayIcons {
+	if style == "mono" {
+		style = "white"
+		if lightTaskbar() {
+			style = "black"
+		}
+	}
+	switch style {
+	case "white", "black":
+		return &trayIcons{
+			playing: loadIcon(icons, "icons/ambiantgo-"+style+".ico"),
+			paused:  loadIcon(icons, "icons/ambiantgo-"+style+"-paused.ico"),
+		}
+	}
+	return &trayIcons{
+		playing: loadIcon(icons, "ambiantgo.ico"),
+		paused:  loadIcon(icons, "icons/ambiantgo-paused.ico"),
+	}
+}
+
+// icon returns the icon to show for status, and false when it is
+// already showing
+func (t *trayIcons) icon(status audio.Status) ([]byte, bool) {
+	if t.set && t.shown == status.Playing {
+		return nil, false
+	}
+	t.set, t.shown = true, status.Playing
+	if status.Playing {
+		return t.playing, true
+	}
+	return t.paused, true
+}
@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// sessionEvent is a change in whether anyone can be listening
+type sessionEvent int
+
+const (
+	sessionLocked sessionEvent = iota
+	sessionUnlocked
+	systemSuspending
+	systemResumed
+)
+
+// AwayPolicy pauses playback while the session is locked or the system
+// sleeps, and optionally resumes it when the user is back
+type AwayPolicy struct {
+	Pause  bool
+	Resume bool
+
+	locked     bool
+	autoPaused bool // playback was paused by the policy, not the user
+}
+
+// handle applies the policy to a session event. It reports whether a
+// resume was refused because canPlay was false.
+func (p *AwayPolicy) handle(sp *audio.Player, event sessionEvent, canPlay bool) (refused bool) {
+	switch event {
+	case sessionLocked, systemSuspending:
+		if event == sessionLocked {
+			p.locked = true
+		}
+		if p.Pause && sp.IsPlaying() {
+			sp.Pause()
+			p.autoPaused = true
+		}
+	case sessionUnlocked, systemResumed:
+		if event == sessionUnlocked {
+			p.locked = false
+		}
+		// Waking up to a lock screen waits for the unlock
+		if p.locked || !p.autoPaused {
+			return
+		}
+		p.autoPaused = false
+		if p.Resume && !sp.IsPlaying() {
+			if !canPlay {
+				return true
+			}
+			sp.Play()
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"os"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// watchSession reports when the session is locked or unlocked, through
+// logind and the desktop's screensaver, and when the system suspends or
+// resumes, through logind
+func watchSession() <-chan sessionEvent {
+	events := make(chan sessionEvent, 4)
+	send := func(event sessionEvent) {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+
+	if system, err := dbus.ConnectSystemBus(); err != nil {
+		logger.Error("Watching for session lock failed", "err", err)
+	} else {
+		watchLogind(system, send)
+	}
+
+	if session, err := dbus.ConnectSessionBus(); err != nil {
+		logger.Error("Watching the screensaver failed", "err", err)
+	} else {
+		watchScreensaver(session, send)
+	}
+	return events
+}
+
+func watchLogind(conn *dbus.Conn, send func(sessionEvent)) {
+	var sessionPath dbus.ObjectPath
+	manager := conn.Object("org.freedesktop.login1", "/org/freedesktop/login1")
+	if err := manager.Call("org.freedesktop.login1.Manager.GetSessionByPID", 0, uint32(os.Getpid())).Store(&sessionPath); err != nil {
+		logger.Error("Finding the login session failed", "err", err)
+	}
+
+	conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.login1.Manager"),
+		dbus.WithMatchMember("PrepareForSleep"),
+	)
+	if sessionPath != "" {
+		conn.AddMatchSignal(
+			dbus.WithMatchObjectPath(sessionPath),
+			dbus.WithMatchInterface("org.freedesktop.login1.Session"),
+		)
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	go func() {
+		for sig := range signals {
+			switch sig.Name {
+			case "org.freedesktop.login1.Manager.PrepareForSleep":
+				var sleeping bool
+				if dbus.Store(sig.Body, &sleeping) != nil {
+					continue
+				}
+				if sleeping {
+					send(systemSuspending)
+				} else {
+					send(systemResumed)
+				}
+			case "org.freedesktop.login1.Session.Lock":
+				send(sessionLocked)
+			case "org.freedesktop.login1.Session.Unlock":
+				send(sessionUnlocked)
+			}
+		}
+	}()
+}
+
+// watchScreensaver follows the lock screen of GNOME and of desktops
+// implementing the freedesktop screensaver interface, e.g. KDE
+func watchScreensaver(conn *dbus.Conn, send func(sessionEvent)) {
+	for _, iface := range []string{"org.gnome.ScreenSaver", "org.freedesktop.ScreenSaver"} {
+		conn.AddMatchSignal(
+			dbus.WithMatchInterface(iface),
+			dbus.WithMatchMember("ActiveChanged"),
+		)
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	go func() {
+		for sig := range signals {
+			var active bool
+			if dbus.Store(sig.Body, &active) != nil {
+				continue
+			}
+			if active {
+				send(sessionLocked)
+			} else {
+				send(sessionUnlocked)
+			}
+		}
+	}()
+}
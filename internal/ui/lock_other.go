@@ -0,0 +1,8 @@
+//go:build !windows && !linux
+
+package ui
+
+// watchSession returns nil; locking and sleep aren't detected here yet
+func watchSession() <-chan sessionEvent {
+	return nil
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	wtsapi32                             = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSRegisterSessionNotification   = wtsapi32.NewProc("WTSRegisterSessionNotification")
+	procWTSUnRegisterSessionNotification = wtsapi32.NewProc("WTSUnRegisterSessionNotification")
+)
+
+const (
+	wmPowerBroadcast      = 0x0218
+	wmWTSSessionChange    = 0x02B1
+	wtsSessionLock        = 0x7
+	wtsSessionUnlock      = 0x8
+	pbtAPMSuspend         = 0x4
+	pbtAPMResumeAutomatic = 0x12
+	notifyForThisSession  = 0
+)
+
+// sessionEvents receives the events seen by the window procedure, which
+// can't take a closure
+var sessionEvents chan sessionEvent
+
+func sessionWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	var event sessionEvent
+	switch {
+	case msg == wmWTSSessionChange && wParam == wtsSessionLock:
+		event = sessionLocked
+	case msg == wmWTSSessionChange && wParam == wtsSessionUnlock:
+		event = sessionUnlocked
+	case msg == wmPowerBroadcast && wParam == pbtAPMSuspend:
+		event = systemSuspending
+	case msg == wmPowerBroadcast && wParam == pbtAPMResumeAutomatic:
+		event = systemResumed
+	default:
+		r, _, _ := procDefWindowProc.Call(hwnd, msg, wParam, lParam)
+		return r
+	}
+
+	select {
+	case sessionEvents <- event:
+	default:
+	}
+	return 1
+}
+
+// watchSession reports when the workstation is locked or unlocked and when
+// the system suspends or resumes
+func watchSession() <-chan sessionEvent {
+	sessionEvents = make(chan sessionEvent, 4)
+
+	go func() {
+		// Window messages are delivered to the thread that made the window
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		hwnd, err := hiddenWindow("AmbiantGoSession", syscall.NewCallback(sessionWndProc))
+		if err != nil {
+			logger.Error("Watching for session lock failed", "err", err)
+			return
+		}
+		if r, _, err := procWTSRegisterSessionNotification.Call(hwnd, notifyForThisSession); r == 0 {
+			logger.Error("Watching for session lock failed", "err", err)
+		}
+		defer procWTSUnRegisterSessionNotification.Call(hwnd)
+
+		var m winMsg
+		for {
+			r, _, _ := procGetMessage.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+			if int32(r) <= 0 {
+				return
+			}
+			procDispatchMessage.Call(uintptr(unsafe.Pointer(&m)))
+		}
+	}()
+
+	return sessionEvents
+}
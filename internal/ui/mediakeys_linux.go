@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	mediaKeysService   = "org.gnome.SettingsDaemon.MediaKeys"
+	mediaKeysPath      = "/org/gnome/SettingsDaemon/MediaKeys"
+	mediaKeysInterface = "org.gnome.SettingsDaemon.MediaKeys"
+	mediaKeysApp       = "AmbiantGo"
+)
+
+// mediaKeyBindings returns nil; media keys come from the desktop on Linux
+func mediaKeyBindings() []hotkeyBinding {
+	return nil
+}
+
+// watchMediaKeys asks the GNOME settings daemon for the media keys and
+// reports the action of each one pressed on the returned channel
+func watchMediaKeys() <-chan string {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		logger.Error("Connecting to the session bus for media keys failed", "err", err)
+		return nil
+	}
+
+	obj := conn.Object(mediaKeysService, mediaKeysPath)
+	if err := obj.Call(mediaKeysInterface+".GrabMediaPlayerKeys", 0, mediaKeysApp, uint32(0)).Err; err != nil {
+		logger.Error("Grabbing media keys failed", "err", err)
+		conn.Close()
+		return nil
+	}
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface(mediaKeysInterface),
+		dbus.WithMatchMember("MediaPlayerKeyPressed"),
+	); err != nil {
+		logger.Error("Listening for media keys failed", "err", err)
+		conn.Close()
+		return nil
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+
+	pressed := make(chan string, 4)
+	go func() {
+		for sig := range signals {
+			var app, key string
+			if err := dbus.Store(sig.Body, &app, &key); err != nil || app != mediaKeysApp {
+				continue
+			}
+
+			var action string
+			switch key {
+			case "Play", "Pause":
+				action = hotkeyToggle
+			case "Stop":
+				action = hotkeyPause
+			case "Next":
+				action = hotkeyNext
+			case "Previous":
+				action = hotkeyPrevious
+			default:
+				continue
+			}
+
+			select {
+			case pressed <- action:
+			default:
+			}
+		}
+	}()
+	return pressed
+}
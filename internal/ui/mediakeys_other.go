@@ -0,0 +1,13 @@
+//go:build !windows && !linux
+
+package ui
+
+// mediaKeyBindings returns nil; media keys aren't supported here yet
+func mediaKeyBindings() []hotkeyBinding {
+	return nil
+}
+
+// watchMediaKeys returns nil; media keys aren't supported here yet
+func watchMediaKeys() <-chan string {
+	return nil
+}
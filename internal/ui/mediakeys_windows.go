@@ -0,0 +1,16 @@
+package ui
+
+// mediaKeyBindings are the media keys, registered like hotkeys on Windows
+func mediaKeyBindings() []hotkeyBinding {
+	return []hotkeyBinding{
+		{hotkeyToggle, "MediaPlayPause"},
+		{hotkeyNext, "MediaNext"},
+		{hotkeyPrevious, "MediaPrevious"},
+		{hotkeyPause, "MediaStop"},
+	}
+}
+
+// watchMediaKeys returns nil; media keys arrive as hotkeys on Windows
+func watchMediaKeys() <-chan string {
+	return nil
+}
@@ -0,0 +1,171 @@
+package ui
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+	"rogverse.fyi/ambiantgo/internal/remote"
+)
+
+const (
+	mprisName   = "org.mpris.MediaPlayer2.ambiantgo"
+	mprisPath   = "/org/mpris/MediaPlayer2"
+	mprisRoot   = "org.mpris.MediaPlayer2"
+	mprisPlayer = "org.mpris.MediaPlayer2.Player"
+	mprisNoMix  = "/org/mpris/MediaPlayer2/TrackList/NoTrack"
+	mprisMix    = "/org/ambiantgo/mix"
+)
+
+// mprisApp implements the org.mpris.MediaPlayer2 methods
+type mprisApp struct{}
+
+func (mprisApp) Raise() *dbus.Error { return nil }
+func (mprisApp) Quit() *dbus.Error  { return nil }
+
+// mprisControls implements org.mpris.MediaPlayer2.Player, forwarding to the
+// event loop like the control API
+type mprisControls struct {
+	rc *remote.Control
+}
+
+func (m mprisControls) command(action string) *dbus.Error {
+	if err := m.rc.Run(action, "", ""); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (m mprisControls) Play() *dbus.Error      { return m.command("play") }
+func (m mprisControls) Pause() *dbus.Error     { return m.command("pause") }
+func (m mprisControls) PlayPause() *dbus.Error { return m.command("toggle") }
+func (m mprisControls) Stop() *dbus.Error      { return m.command("pause") }
+func (m mprisControls) Next() *dbus.Error      { return m.command("next") }
+func (m mprisControls) Previous() *dbus.Error  { return m.command("previous") }
+
+// Ambience has no timeline to seek in
+func (m mprisControls) Seek(offset int64) *dbus.Error { return nil }
+
+func (m mprisControls) SetPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	return nil
+}
+
+func (m mprisControls) OpenUri(uri string) *dbus.Error {
+	return dbus.MakeFailedError(remote.ErrUnknownCommand)
+}
+
+// StartMediaSession publishes the player on D-Bus as an MPRIS2 service, so
+// desktop media controls and playerctl can show and drive it. The
+// returned function updates what they show.
+func StartMediaSession(rc *remote.Control) func(audio.Status) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		logger.Error("Connecting to the session bus for MPRIS failed", "err", err)
+		return func(audio.Status) {}
+	}
+
+	reply, err := conn.RequestName(mprisName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		logger.Error("Registering the MPRIS name failed", "name", mprisName, "err", err)
+		conn.Close()
+		return func(audio.Status) {}
+	}
+
+	controls := mprisControls{rc}
+	conn.Export(mprisApp{}, mprisPath, mprisRoot)
+	conn.Export(controls, mprisPath, mprisPlayer)
+
+	props, err := prop.Export(conn, mprisPath, prop.Map{
+		mprisRoot: {
+			"CanQuit":             {Value: false, Emit: prop.EmitConst},
+			"CanRaise":            {Value: false, Emit: prop.EmitConst},
+			"HasTrackList":        {Value: false, Emit: prop.EmitConst},
+			"Identity":            {Value: "AmbiantGo", Emit: prop.EmitConst},
+			"SupportedUriSchemes": {Value: []string{}, Emit: prop.EmitConst},
+			"SupportedMimeTypes":  {Value: []string{}, Emit: prop.EmitConst},
+		},
+		mprisPlayer: {
+			"PlaybackStatus": {Value: "Stopped", Emit: prop.EmitTrue},
+			"Metadata":       {Value: mprisMetadata(audio.Status{}), Emit: prop.EmitTrue},
+			"Volume": {
+				Value:    1.0,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: func(c *prop.Change) *dbus.Error {
+					volume, ok := c.Value.(float64)
+					if !ok {
+						return prop.ErrInvalidArg
+					}
+					volume = min(max(volume, 0), 1)
+					// The properties are locked while this runs, and the
+					// event loop updates them, so don't wait for it
+					go rc.Run("volume", strconv.FormatFloat(volume, 'f', -1, 64), "")
+					return nil
+				},
+			},
+			"Position":      {Value: int64(0), Emit: prop.EmitFalse},
+			"Rate":          {Value: 1.0, Emit: prop.EmitConst},
+			"MinimumRate":   {Value: 1.0, Emit: prop.EmitConst},
+			"MaximumRate":   {Value: 1.0, Emit: prop.EmitConst},
+			"CanGoNext":     {Value: true, Emit: prop.EmitConst},
+			"CanGoPrevious": {Value: true, Emit: prop.EmitConst},
+			"CanPlay":       {Value: true, Emit: prop.EmitConst},
+			"CanPause":      {Value: true, Emit: prop.EmitConst},
+			"CanSeek":       {Value: false, Emit: prop.EmitConst},
+			"CanControl":    {Value: true, Emit: prop.EmitConst},
+		},
+	})
+	if err != nil {
+		logger.Error("Exporting MPRIS properties failed", "err", err)
+		conn.Close()
+		return func(audio.Status) {}
+	}
+
+	node := &introspect.Node{
+		Name: mprisPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: mprisRoot, Methods: introspect.Methods(mprisApp{}), Properties: props.Introspection(mprisRoot)},
+			{Name: mprisPlayer, Methods: introspect.Methods(controls), Properties: props.Introspection(mprisPlayer)},
+		},
+	}
+	conn.Export(introspect.NewIntrospectable(node), mprisPath, "org.freedesktop.DBus.Introspectable")
+
+	var last audio.Status
+	return func(s audio.Status) {
+		if s.Playing == last.Playing && s.Volume == last.Volume && s.NowPlaying == last.NowPlaying {
+			return
+		}
+		last = s
+
+		status := "Paused"
+		switch {
+		case s.Playing:
+			status = "Playing"
+		case len(s.Sounds) == 0:
+			status = "Stopped"
+		}
+		props.SetMust(mprisPlayer, "PlaybackStatus", status)
+		props.SetMust(mprisPlayer, "Metadata", mprisMetadata(s))
+		props.SetMust(mprisPlayer, "Volume", s.Volume)
+	}
+}
+
+// mprisMetadata describes the mix as a track named after its sounds
+func mprisMetadata(s audio.Status) map[string]dbus.Variant {
+	if len(s.Sounds) == 0 {
+		return map[string]dbus.Variant{
+			"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(mprisNoMix)),
+		}
+	}
+	return map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(mprisMix)),
+		"xesam:title":   dbus.MakeVariant(strings.Join(s.Sounds, " + ")),
+		"xesam:artist":  dbus.MakeVariant([]string{"AmbiantGo"}),
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows && !linux
+
+package ui
+
+import (
+	"rogverse.fyi/ambiantgo/internal/audio"
+	"rogverse.fyi/ambiantgo/internal/remote"
+)
+
+// StartMediaSession returns a no-op; the player isn't shown in the OS
+// media controls here
+func StartMediaSession(rc *remote.Control) func(audio.Status) {
+	return func(audio.Status) {}
+}
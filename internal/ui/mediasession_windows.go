@@ -0,0 +1,290 @@
+package ui
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+	"rogverse.fyi/ambiantgo/internal/remote"
+	"rogverse.fyi/ambiantgo/internal/winapi"
+)
+
+var (
+	combase                    = syscall.NewLazyDLL("combase.dll")
+	procRoGetActivationFactory = combase.NewProc("RoGetActivationFactory")
+	procWindowsCreateString    = combase.NewProc("WindowsCreateString")
+	procWindowsDeleteString    = combase.NewProc("WindowsDeleteString")
+
+	procGetCurrentThreadID = winapi.Kernel32.NewProc("GetCurrentThreadId")
+
+	procPostThreadMessage = winapi.User32.NewProc("PostThreadMessageW")
+
+	iidSMTCInterop = syscall.GUID{Data1: 0xDDB0472D, Data2: 0xC911, Data3: 0x4A1F, Data4: [8]byte{0x86, 0xD9, 0xDC, 0x3D, 0x71, 0xA9, 0x5F, 0x5A}}
+	iidSMTC        = syscall.GUID{Data1: 0x99FA3FF4, Data2: 0x1742, Data3: 0x42A6, Data4: [8]byte{0x90, 0x2E, 0x08, 0x7D, 0x41, 0xF9, 0x65, 0xEC}}
+	// ITypedEventHandler<SystemMediaTransportControls, SystemMediaTransportControlsButtonPressedEventArgs>
+	iidButtonHandler = syscall.GUID{Data1: 0x0557E996, Data2: 0x7B23, Data3: 0x5BAE, Data4: [8]byte{0xAA, 0x81, 0xEA, 0x0D, 0x67, 0x11, 0x43, 0xA4}}
+	iidIUnknown      = syscall.GUID{Data1: 0x00000000, Data2: 0x0000, Data3: 0x0000, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	iidIAgileObject  = syscall.GUID{Data1: 0x94EA2B94, Data2: 0xE9CC, Data3: 0x49E0, Data4: [8]byte{0xC0, 0xFF, 0xEE, 0x64, 0xCA, 0x8F, 0x5B, 0x90}}
+)
+
+const (
+	wmApp           = 0x8000
+	eNoInterface    = 0x80004002
+	playbackPlaying = 3
+	playbackPaused  = 4
+	playbackStopped = 2
+	mediaTypeMusic  = 1
+
+	// vtable slots; 0-5 are the IUnknown and IInspectable methods
+	smtcInteropGetForWindow  = 6
+	smtcPutPlaybackStatus    = 7
+	smtcGetDisplayUpdater    = 8
+	smtcPutIsEnabled         = 11
+	smtcPutIsPlayEnabled     = 13
+	smtcPutIsPauseEnabled    = 17
+	smtcPutIsPreviousEnabled = 25
+	smtcPutIsNextEnabled     = 27
+	smtcAddButtonPressed     = 32
+	displayUpdaterPutType    = 7
+	displayUpdaterGetMusic   = 12
+	displayUpdaterUpdate     = 17
+	musicPutTitle            = 7
+	musicPutArtist           = 11
+	buttonArgsGetButton      = 6
+
+	// SystemMediaTransportControlsButton values
+	buttonPlay     = 0
+	buttonPause    = 1
+	buttonStop     = 2
+	buttonNext     = 6
+	buttonPrevious = 7
+)
+
+// buttonHandler is a COM delegate receiving the SMTC button presses
+type buttonHandler struct {
+	vtbl *[4]uintptr
+	rc   *remote.Control
+}
+
+var (
+	// The delegate and its vtable must outlive every call Windows makes
+	theButtonHandler   *buttonHandler
+	buttonHandlerVtbl  [4]uintptr
+	buttonHandlerSetup sync.Once
+)
+
+func newButtonHandler(rc *remote.Control) *buttonHandler {
+	buttonHandlerSetup.Do(func() {
+		buttonHandlerVtbl = [4]uintptr{
+			syscall.NewCallback(buttonHandlerQueryInterface),
+			syscall.NewCallback(func(this uintptr) uintptr { return 1 }),
+			syscall.NewCallback(func(this uintptr) uintptr { return 1 }),
+			syscall.NewCallback(buttonHandlerInvoke),
+		}
+	})
+	theButtonHandler = &buttonHandler{vtbl: &buttonHandlerVtbl, rc: rc}
+	return theButtonHandler
+}
+
+func buttonHandlerQueryInterface(this uintptr, iid *syscall.GUID, out *uintptr) uintptr {
+	if *iid == iidIUnknown || *iid == iidIAgileObject || *iid == iidButtonHandler {
+		*out = this
+		return 0
+	}
+	*out = 0
+	return eNoInterface
+}
+
+func buttonHandlerInvoke(this, sender uintptr, args unsafe.Pointer) uintptr {
+	var button int32
+	if hr := winapi.ComCall(args, buttonArgsGetButton, uintptr(unsafe.Pointer(&button))); int32(hr) < 0 {
+		return 0
+	}
+
+	var action string
+	switch button {
+	case buttonPlay:
+		action = "play"
+	case buttonPause, buttonStop:
+		action = "pause"
+	case buttonNext:
+		action = "next"
+	case buttonPrevious:
+		action = "previous"
+	default:
+		return 0
+	}
+
+	// Don't hold up the Windows thread delivering the event
+	go theButtonHandler.rc.Run(action, "", "")
+	return 0
+}
+
+// newHString creates a WinRT string; it must be freed with deleteHString
+func newHString(s string) (uintptr, error) {
+	u, err := syscall.UTF16FromString(s)
+	if err != nil {
+		return 0, err
+	}
+	var h uintptr
+	hr, _, _ := procWindowsCreateString.Call(uintptr(unsafe.Pointer(&u[0])), uintptr(len(u)-1), uintptr(unsafe.Pointer(&h)))
+	if int32(hr) < 0 {
+		return 0, fmt.Errorf("WindowsCreateString failed: 0x%08x", uint32(hr))
+	}
+	return h, nil
+}
+
+func deleteHString(h uintptr) {
+	procWindowsDeleteString.Call(h)
+}
+
+// mediaControls is the System Media Transport Controls of the app window
+type mediaControls struct {
+	smtc unsafe.Pointer
+	last audio.Status
+}
+
+func newMediaControls(rc *remote.Control) (*mediaControls, error) {
+	hwnd, err := hiddenWindow("AmbiantGoMedia", procDefWindowProc.Addr())
+	if err != nil {
+		return nil, err
+	}
+
+	class, err := newHString("Windows.Media.SystemMediaTransportControls")
+	if err != nil {
+		return nil, err
+	}
+	defer deleteHString(class)
+
+	var interop unsafe.Pointer
+	hr, _, _ := procRoGetActivationFactory.Call(class, uintptr(unsafe.Pointer(&iidSMTCInterop)), uintptr(unsafe.Pointer(&interop)))
+	if int32(hr) < 0 {
+		return nil, fmt.Errorf("RoGetActivationFactory failed: 0x%08x", uint32(hr))
+	}
+	defer winapi.ComRelease(interop)
+
+	m := &mediaControls{}
+	if hr := winapi.ComCall(interop, smtcInteropGetForWindow, hwnd, uintptr(unsafe.Pointer(&iidSMTC)), uintptr(unsafe.Pointer(&m.smtc))); int32(hr) < 0 {
+		return nil, fmt.Errorf("GetForWindow failed: 0x%08x", uint32(hr))
+	}
+
+	for _, slot := range []int{smtcPutIsEnabled, smtcPutIsPlayEnabled, smtcPutIsPauseEnabled, smtcPutIsNextEnabled, smtcPutIsPreviousEnabled} {
+		winapi.ComCall(m.smtc, slot, 1)
+	}
+
+	var token int64
+	handler := newButtonHandler(rc)
+	if hr := winapi.ComCall(m.smtc, smtcAddButtonPressed, uintptr(unsafe.Pointer(handler)), uintptr(unsafe.Pointer(&token))); int32(hr) < 0 {
+		logger.Error("Handling media control buttons failed", "hresult", fmt.Sprintf("0x%08x", uint32(hr)))
+	}
+	return m, nil
+}
+
+// update shows the mix and playing state in the media overlay
+func (m *mediaControls) update(s audio.Status) {
+	if s.Playing == m.last.Playing && s.NowPlaying == m.last.NowPlaying {
+		return
+	}
+	m.last = s
+
+	status := playbackPaused
+	switch {
+	case s.Playing:
+		status = playbackPlaying
+	case len(s.Sounds) == 0:
+		status = playbackStopped
+	}
+	winapi.ComCall(m.smtc, smtcPutPlaybackStatus, uintptr(status))
+
+	var updater unsafe.Pointer
+	if hr := winapi.ComCall(m.smtc, smtcGetDisplayUpdater, uintptr(unsafe.Pointer(&updater))); int32(hr) < 0 {
+		return
+	}
+	defer winapi.ComRelease(updater)
+	winapi.ComCall(updater, displayUpdaterPutType, mediaTypeMusic)
+
+	var music unsafe.Pointer
+	if hr := winapi.ComCall(updater, displayUpdaterGetMusic, uintptr(unsafe.Pointer(&music))); int32(hr) < 0 {
+		return
+	}
+	defer winapi.ComRelease(music)
+
+	for slot, text := range map[int]string{musicPutTitle: strings.Join(s.Sounds, " + "), musicPutArtist: "AmbiantGo"} {
+		if h, err := newHString(text); err == nil {
+			winapi.ComCall(music, slot, h)
+			deleteHString(h)
+		}
+	}
+	winapi.ComCall(updater, displayUpdaterUpdate)
+}
+
+// StartMediaSession registers the app with the System Media Transport
+// Controls, so the volume flyout and media overlay show the mix with
+// working buttons. The returned function updates what they show.
+func StartMediaSession(rc *remote.Control) func(audio.Status) {
+	var (
+		mu      sync.Mutex
+		pending *audio.Status
+	)
+	threadID := make(chan uintptr, 1)
+
+	go func() {
+		// The window and the controls belong to this thread
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		uninit, err := winapi.ComInit()
+		if err != nil {
+			logger.Error("Starting media controls failed", "err", err)
+			threadID <- 0
+			return
+		}
+		defer uninit()
+
+		controls, err := newMediaControls(rc)
+		if err != nil {
+			logger.Error("Starting media controls failed", "err", err)
+			threadID <- 0
+			return
+		}
+		defer winapi.ComRelease(controls.smtc)
+
+		id, _, _ := procGetCurrentThreadID.Call()
+		threadID <- id
+
+		var m winMsg
+		for {
+			r, _, _ := procGetMessage.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+			if int32(r) <= 0 {
+				return
+			}
+			if m.hwnd == 0 && m.message == wmApp {
+				mu.Lock()
+				s := pending
+				pending = nil
+				mu.Unlock()
+				if s != nil {
+					controls.update(*s)
+				}
+				continue
+			}
+			procDispatchMessage.Call(uintptr(unsafe.Pointer(&m)))
+		}
+	}()
+
+	id := <-threadID
+	return func(s audio.Status) {
+		if id == 0 {
+			return
+		}
+		mu.Lock()
+		pending = &s
+		mu.Unlock()
+		// Wake the media thread to apply the latest state
+		procPostThreadMessage.Call(id, wmApp, 0, 0)
+	}
+}
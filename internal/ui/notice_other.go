@@ -0,0 +1,8 @@
+//go:build !windows
+
+package ui
+
+// ShowNotice logs the message; systray has no notification support here
+func ShowNotice(title, message string) {
+	logger.Info(message, "title", title)
+}
@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"rogverse.fyi/ambiantgo/internal/winapi"
+)
+
+var (
+	shell32                      = syscall.NewLazyDLL("shell32.dll")
+	procMessageBox               = winapi.User32.NewProc("MessageBoxW")
+	procFindWindowEx             = winapi.User32.NewProc("FindWindowExW")
+	procGetWindowThreadProcessID = winapi.User32.NewProc("GetWindowThreadProcessId")
+	procGetCurrentProcessID      = winapi.Kernel32.NewProc("GetCurrentProcessId")
+	procShellNotifyIcon          = shell32.NewProc("Shell_NotifyIconW")
+)
+
+const (
+	mbIconInformation = 0x40
+	mbSystemModal     = 0x1000
+
+	nimModify = 0x1
+	nifInfo   = 0x10
+	niifInfo  = 0x1
+
+	// The window class and icon ID systray gives its tray icon
+	trayClass  = "SystrayClass"
+	trayIconID = 100
+)
+
+// notifyIconData is NOTIFYICONDATAW
+type notifyIconData struct {
+	size            uint32
+	wnd             uintptr
+	id              uint32
+	flags           uint32
+	callbackMessage uint32
+	icon            uintptr
+	tip             [128]uint16
+	state           uint32
+	stateMask       uint32
+	info            [256]uint16
+	timeout         uint32
+	infoTitle       [64]uint16
+	infoFlags       uint32
+	guidItem        struct {
+		data1        uint32
+		data2, data3 uint16
+		data4        [8]byte
+	}
+	balloonIcon uintptr
+}
+
+// noticeOpen is set while a fallback message box is showing
+var noticeOpen atomic.Bool
+
+// ShowNotice shows a notification from the tray icon without blocking
+// the caller. Before the tray is up it falls back to a message box, only
+// one at a time so they don't pile up while the user is away.
+func ShowNotice(title, message string) {
+	if showBalloon(title, message) {
+		return
+	}
+	if !noticeOpen.CompareAndSwap(false, true) {
+		logger.Info(message, "title", title)
+		return
+	}
+
+	titlePtr, err := syscall.UTF16PtrFromString(title)
+	if err != nil {
+		noticeOpen.Store(false)
+		return
+	}
+	messagePtr, err := syscall.UTF16PtrFromString(message)
+	if err != nil {
+		noticeOpen.Store(false)
+		return
+	}
+	go func() {
+		defer noticeOpen.Store(false)
+		procMessageBox.Call(0, uintptr(unsafe.Pointer(messagePtr)), uintptr(unsafe.Pointer(titlePtr)), mbIconInformation)
+	}()
+}
+
+// showBalloon shows a notification balloon, a toast on Windows 10 and
+// later, from the tray icon, reporting false if there is no icon yet
+func showBalloon(title, message string) bool {
+	wnd := trayWindow()
+	if wnd == 0 {
+		return false
+	}
+
+	nid := notifyIconData{wnd: wnd, id: trayIconID, flags: nifInfo, infoFlags: niifInfo}
+	nid.size = uint32(unsafe.Sizeof(nid))
+	copyUTF16(nid.infoTitle[:], title)
+	copyUTF16(nid.info[:], message)
+	ok, _, _ := procShellNotifyIcon.Call(nimModify, uintptr(unsafe.Pointer(&nid)))
+	return ok != 0
+}
+
+// trayWindow finds the hidden window that owns this process's tray icon
+func trayWindow() uintptr {
+	class, _ := syscall.UTF16PtrFromString(trayClass)
+	pid, _, _ := procGetCurrentProcessID.Call()
+	var wnd uintptr
+	for {
+		wnd, _, _ = procFindWindowEx.Call(0, wnd, uintptr(unsafe.Pointer(class)), 0)
+		if wnd == 0 {
+			return 0
+		}
+		var owner uint32
+		procGetWindowThreadProcessID.Call(wnd, uintptr(unsafe.Pointer(&owner)))
+		if uintptr(owner) == pid {
+			return wnd
+		}
+	}
+}
+
+// copyUTF16 copies s into a fixed-size field, cut short to fit and
+// NUL-terminated
+func copyUTF16(dst []uint16, s string) {
+	src, _ := syscall.UTF16FromString(s)
+	n := copy(dst[:len(dst)-1], src)
+	dst[n] = 0
+}
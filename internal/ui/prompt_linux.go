@@ -0,0 +1,29 @@
+package ui
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// promptText asks for a line of text in a dialog, with value filled in.
+// It blocks until the dialog is closed and reports false if it was
+// cancelled or no dialog tool is installed.
+func promptText(title, label, value string) (string, bool) {
+	cmd := exec.Command("zenity", "--entry", "--title", title, "--text", label, "--entry-text", value)
+	if _, err := exec.LookPath("zenity"); err != nil {
+		cmd = exec.Command("kdialog", "--title", title, "--inputbox", label, value)
+	}
+	out, err := cmd.Output()
+	text := strings.TrimSpace(string(out))
+	return text, err == nil && text != ""
+}
+
+// confirm asks a yes or no question in a dialog, blocking until it is
+// answered, and reports whether the answer was yes
+func confirm(title, question string) bool {
+	cmd := exec.Command("zenity", "--question", "--title", title, "--text", question)
+	if _, err := exec.LookPath("zenity"); err != nil {
+		cmd = exec.Command("kdialog", "--title", title, "--yesno", question)
+	}
+	return cmd.Run() == nil
+}
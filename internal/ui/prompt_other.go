@@ -0,0 +1,28 @@
+//go:build !windows && !linux
+
+package ui
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// promptText asks for a line of text in a dialog, with value filled in.
+// It blocks until the dialog is closed and reports false if it was
+// cancelled.
+func promptText(title, label, value string) (string, bool) {
+	script := "text returned of (display dialog " + strconv.Quote(label) +
+		" with title " + strconv.Quote(title) + " default answer " + strconv.Quote(value) + ")"
+	out, err := exec.Command("osascript", "-e", script).Output()
+	text := strings.TrimSpace(string(out))
+	return text, err == nil && text != ""
+}
+
+// confirm asks a yes or no question in a dialog, blocking until it is
+// answered, and reports whether the answer was yes
+func confirm(title, question string) bool {
+	script := "display dialog " + strconv.Quote(question) + " with title " + strconv.Quote(title) +
+		` buttons {"No", "Yes"} default button "Yes" cancel button "No"`
+	return exec.Command("osascript", "-e", script).Run() == nil
+}
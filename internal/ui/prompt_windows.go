@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// promptText asks for a line of text in a dialog, with value filled in.
+// It blocks until the dialog is closed and reports false if it was
+// cancelled.
+func promptText(title, label, value string) (string, bool) {
+	quote := func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	// Windows has no input box API, the VisualBasic one is the closest
+	script := "Add-Type -AssemblyName Microsoft.VisualBasic; [Microsoft.VisualBasic.Interaction]::InputBox(" +
+		quote(label) + ", " + quote(title) + ", " + quote(value) + ")"
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.Output()
+	text := strings.TrimSpace(string(out))
+	return text, err == nil && text != ""
+}
+
+const (
+	mbYesNo        = 0x4
+	mbIconQuestion = 0x20
+	idYes          = 6
+)
+
+// confirm asks a yes or no question in a message box, blocking until it
+// is answered, and reports whether the answer was yes
+func confirm(title, question string) bool {
+	titlePtr, err := syscall.UTF16PtrFromString(title)
+	if err != nil {
+		return false
+	}
+	questionPtr, err := syscall.UTF16PtrFromString(question)
+	if err != nil {
+		return false
+	}
+	answer, _, _ := procMessageBox.Call(0, uintptr(unsafe.Pointer(questionPtr)), uintptr(unsafe.Pointer(titlePtr)), mbYesNo|mbIconQuestion|mbSystemModal)
+	return answer == idYes
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"github.com/getlantern/systray"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// shortcutMenu lists some sounds of the library again at the top of the
+// Sounds menu, as Favorites and Recent do. systray can't remove items, so
+// its items are reused and more are added as the list grows.
+type shortcutMenu struct {
+	parent  *systray.MenuItem
+	items   []*systray.MenuItem
+	sounds  []string // sound of each shown item
+	clicked chan int // item picked
+}
+
+// newShortcutMenu adds the submenu under sounds, hidden while it is empty
+func newShortcutMenu(sounds *systray.MenuItem, title, tooltip string) *shortcutMenu {
+	m := &shortcutMenu{
+		parent:  sounds.AddSubMenuItem(title, tooltip),
+		clicked: make(chan int),
+	}
+	m.parent.Hide()
+	return m
+}
+
+// update lists the sounds that are still in the library, checking the
+// ones in the mix
+func (m *shortcutMenu) update(sp *audio.Player, sounds []string) {
+	m.sounds = m.sounds[:0]
+	for _, sound := range sounds {
+		for _, known := range sp.Sounds {
+			if known == sound {
+				m.sounds = append(m.sounds, sound)
+				break
+			}
+		}
+	}
+
+	for i, sound := range m.sounds {
+		if i == len(m.items) {
+			item := m.parent.AddSubMenuItemCheckbox("", "Add or remove this sound from the mix", false)
+			m.items = append(m.items, item)
+			go func(i int) {
+				for {
+					<-item.ClickedCh
+					m.clicked <- i
+				}
+			}(i)
+		}
+		m.items[i].SetTitle(soundLabel(sound))
+		if sp.Channel(sound) != nil {
+			m.items[i].Check()
+		} else {
+			m.items[i].Uncheck()
+		}
+		m.items[i].Show()
+	}
+	for _, item := range m.items[len(m.sounds):] {
+		item.Hide()
+	}
+	if len(m.sounds) == 0 {
+		m.parent.Hide()
+	} else {
+		m.parent.Show()
+	}
+}
+
+// soundLabel is a sound's title in the tray: its file name, or the name
+// of a built-in sound or stream
+func soundLabel(sound string) string {
+	if audio.IsSynthesized(sound) || audio.IsStream(sound) {
+		return audio.SoundName(sound)
+	}
+	return audio.SoundFile(sound)
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package ui
+
+// lightTaskbar assumes a dark panel, as most Linux and macOS themes have
+func lightTaskbar() bool {
+	return false
+}
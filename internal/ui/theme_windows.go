@@ -0,0 +1,25 @@
+package ui
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// lightTaskbar reports whether Windows shows the taskbar in the light
+// theme, where a white icon would be hard to see
+func lightTaskbar() bool {
+	path, _ := syscall.UTF16PtrFromString(`Software\Microsoft\Windows\CurrentVersion\Themes\Personalize`)
+	var key syscall.Handle
+	if syscall.RegOpenKeyEx(syscall.HKEY_CURRENT_USER, path, 0, syscall.KEY_READ, &key) != nil {
+		return false
+	}
+	defer syscall.RegCloseKey(key)
+
+	name, _ := syscall.UTF16PtrFromString("SystemUsesLightTheme")
+	var value uint32
+	size := uint32(unsafe.Sizeof(value))
+	if syscall.RegQueryValueEx(key, name, nil, nil, (*byte)(unsafe.Pointer(&value)), &size) != nil {
+		return false
+	}
+	return value == 1
+}
@@ -0,0 +1,835 @@
+package ui
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getlantern/systray"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// RunTray shows the tray icon and menu and runs the event loop behind
+// them until Quit is picked
+func (a *App) RunTray() {
+	systray.Run(a.onTrayReady, a.cleanup)
+}
+
+func (a *App) onTrayReady() {
+	// The icon shows whether it is playing, set with the state below
+	icons := loadTrayIcons(a.Icons, a.Config.TrayIcon)
+
+	// Now playing header; systray menus have no slider or custom
+	// widgets on any platform, so this is a plain disabled item
+	mNowPlaying := systray.AddMenuItem(a.Player.NowPlaying(), "")
+	mNowPlaying.Disable()
+	systray.AddSeparator()
+
+	// Create menu items
+	mPlay := systray.AddMenuItem("Play", "Play sound")
+	mPause := systray.AddMenuItem("Pause", "Pause sound")
+
+	// Volume submenu, in steps of 10% or typed in
+	mVolume := systray.AddMenuItem("Volume", "Adjust Volume")
+	mVolumeUp := mVolume.AddSubMenuItem("Louder (+10%)", "Turn the volume up")
+	mVolumeDown := mVolume.AddSubMenuItem("Quieter (-10%)", "Turn the volume down")
+	mVolumeSet := mVolume.AddSubMenuItem("Set volume...", "Type in the volume")
+	mMute := systray.AddMenuItemCheckbox("Mute", "Silence the mix, keeping the volume", false)
+	volumeClicked := make(chan int)
+	volumeItems := make(map[int]*systray.MenuItem)
+	for p := 100; p >= 0; p -= volumeStepPercent {
+		item := mVolume.AddSubMenuItemCheckbox(fmt.Sprintf("%d%%", p), fmt.Sprintf("Set the volume to %d%%", p), false)
+		volumeItems[p] = item
+		go func(p int, m *systray.MenuItem) {
+			for {
+				<-m.ClickedCh
+				volumeClicked <- p
+			}
+		}(p, item)
+	}
+
+	// Sounds submenu; every sound has its own submenu to add it to the
+	// mix and set its volume, grouped in a submenu per category
+	mSounds := systray.AddMenuItem("Sounds", "Mix sounds")
+	favorites := newShortcutMenu(mSounds, "Favorites", "Sounds pinned with Favorite")
+	recent := newShortcutMenu(mSounds, "Recent", "Sounds last added to the mix")
+	soundClicked := make(chan string)
+	favoriteClicked := make(chan string)
+	regionChanged := make(chan string)
+	soundVolumeClicked := make(chan soundVolume)
+	soundMenus := make(map[string]*soundMenu)
+	categoryMenus := make(map[string]*systray.MenuItem)
+	var categoryItem func(category string) *systray.MenuItem
+	categoryItem = func(category string) *systray.MenuItem {
+		if category == "" {
+			return mSounds
+		}
+		if item, ok := categoryMenus[category]; ok {
+			return item
+		}
+		parent, name := "", category
+		if i := strings.LastIndex(category, "/"); i >= 0 {
+			parent, name = category[:i], category[i+1:]
+		}
+		item := categoryItem(parent).AddSubMenuItem(name, "Sounds in "+name)
+		categoryMenus[category] = item
+		return item
+	}
+	soundEffectClicked := make(chan soundEffect)
+	addSoundEffectItem := func(item *systray.MenuItem, e soundEffect) {
+		go func() {
+			for {
+				<-item.ClickedCh
+				soundEffectClicked <- e
+			}
+		}()
+	}
+	addSoundItem := func(sound string) {
+		tooltip := "Mix and adjust this sound"
+		if credit := audio.SoundCredit(sound); credit != "" {
+			tooltip = credit
+		}
+		parent := categoryItem(a.Player.Category(sound)).AddSubMenuItemCheckbox(soundLabel(sound), tooltip, a.Player.Channel(sound) != nil)
+		menu := &soundMenu{
+			parent: parent,
+			toggle: parent.AddSubMenuItemCheckbox("In mix", "Add or remove this sound from the mix", a.Player.Channel(sound) != nil),
+			pin:    parent.AddSubMenuItemCheckbox("Favorite", "Pin this sound to Favorites", a.Config.IsFavorite(sound)),
+		}
+		soundMenus[sound] = menu
+
+		// Only sound files have a loop region to edit
+		var region chan struct{}
+		if info, err := os.Stat(sound); err == nil && !info.IsDir() {
+			item := parent.AddSubMenuItem("Loop region...", "Set the part of the file that loops")
+			region = make(chan struct{})
+			go func() {
+				for {
+					<-item.ClickedCh
+					region <- struct{}{}
+				}
+			}()
+		}
+
+		go func(p string, m *soundMenu) {
+			for {
+				select {
+				case <-m.toggle.ClickedCh:
+					soundClicked <- p
+				case <-m.pin.ClickedCh:
+					favoriteClicked <- p
+				case <-region:
+					editLoopRegion(p, regionChanged)
+				}
+			}
+		}(sound, menu)
+
+		for _, level := range audio.ChannelVolumeLevels {
+			item := parent.AddSubMenuItemCheckbox(level.Name, "Set the volume of this sound", level.Volume == a.Player.ChannelVolume(sound))
+			menu.levels = append(menu.levels, item)
+			go func(v soundVolume, m *systray.MenuItem) {
+				for {
+					<-m.ClickedCh
+					soundVolumeClicked <- v
+				}
+			}(soundVolume{sound, level.Volume}, item)
+		}
+
+		// Effects that make one recording sound like another
+		fx := a.Player.SoundEffects(sound)
+		mFX := parent.AddSubMenuItem("Effects", "Change how this sound sounds")
+		mMuffle := mFX.AddSubMenuItem("Muffle", "Hear this sound through a wall")
+		for _, level := range audio.LowPassLevels {
+			item := mMuffle.AddSubMenuItemCheckbox(level.Name, "Cut the highs of this sound", level.Cutoff == fx.LowPass)
+			menu.muffle = append(menu.muffle, item)
+			cutoff := level.Cutoff
+			addSoundEffectItem(item, soundEffect{sound, func(fx *audio.SoundEffects) { fx.LowPass = cutoff }})
+		}
+		mIntensity := mFX.AddSubMenuItem("Intensity", "Thin this sound out or fill it in")
+		for _, level := range audio.IntensityLevels {
+			item := mIntensity.AddSubMenuItemCheckbox(level.Name, "Set the intensity of this sound", level.Intensity == fx.Intensity)
+			menu.intensity = append(menu.intensity, item)
+			intensity := level.Intensity
+			addSoundEffectItem(item, soundEffect{sound, func(fx *audio.SoundEffects) { fx.Intensity = intensity }})
+		}
+		mPan := mFX.AddSubMenuItem("Pan", "Place this sound left or right")
+		for _, level := range audio.PanLevels {
+			item := mPan.AddSubMenuItemCheckbox(level.Name, "Place this sound", level.Pan == fx.Pan)
+			menu.pan = append(menu.pan, item)
+			pan := level.Pan
+			addSoundEffectItem(item, soundEffect{sound, func(fx *audio.SoundEffects) { fx.Pan = pan }})
+		}
+		mWidth := mFX.AddSubMenuItem("Width", "Narrow or widen the stereo image of this sound")
+		for _, level := range audio.WidthLevels {
+			item := mWidth.AddSubMenuItemCheckbox(level.Name, "Set the stereo width of this sound", level.Width == fx.Width)
+			menu.width = append(menu.width, item)
+			width := level.Width
+			addSoundEffectItem(item, soundEffect{sound, func(fx *audio.SoundEffects) { fx.Width = width }})
+		}
+		mSpeed := mFX.AddSubMenuItem("Speed", "Play this sound faster and higher or slower and deeper")
+		for _, level := range audio.SpeedLevels {
+			item := mSpeed.AddSubMenuItemCheckbox(level.Name, "Set the speed of this sound", level.Speed == fx.Speed)
+			menu.speed = append(menu.speed, item)
+			speed := level.Speed
+			addSoundEffectItem(item, soundEffect{sound, func(fx *audio.SoundEffects) { fx.Speed = speed }})
+		}
+	}
+	for _, category := range a.Player.Index() {
+		for _, sound := range category.Sounds {
+			addSoundItem(sound)
+		}
+	}
+
+	// Effects submenu; the equalizer and the reverb apply to the whole mix
+	// and are kept with it in presets
+	mEffects := systray.AddMenuItem("Effects", "Shape the sound of the mix")
+	mEQ := mEffects.AddSubMenuItem("Equalizer", "Adjust bass, mids and treble")
+	eqClicked := make(chan audio.EQ)
+	eqItems := make(map[audio.EQ]*systray.MenuItem)
+	for _, preset := range audio.EQPresets {
+		item := mEQ.AddSubMenuItemCheckbox(preset.Name, "Use this equalizer setting", preset.EQ == a.Player.EQ)
+		eqItems[preset.EQ] = item
+		go func(eq audio.EQ, m *systray.MenuItem) {
+			for {
+				<-m.ClickedCh
+				eqClicked <- eq
+			}
+		}(preset.EQ, item)
+	}
+	mEQCustom := mEQ.AddSubMenuItemCheckbox("Custom...", "Set the gain of each band", false)
+	mReverb := mEffects.AddSubMenuItem("Reverb", "Give the mix a space to play in")
+	reverbClicked := make(chan audio.Reverb)
+	reverbItems := make(map[audio.Reverb]*systray.MenuItem)
+	for _, preset := range audio.ReverbPresets {
+		item := mReverb.AddSubMenuItemCheckbox(preset.Name, "Use this reverb", preset.Reverb == a.Player.Reverb)
+		reverbItems[preset.Reverb] = item
+		go func(r audio.Reverb, m *systray.MenuItem) {
+			for {
+				<-m.ClickedCh
+				reverbClicked <- r
+			}
+		}(preset.Reverb, item)
+	}
+
+	// Eye breaks chime over the ambience at a fixed interval, kept on
+	// across restarts
+	mEyeBreaks := systray.AddMenuItemCheckbox("Eye breaks (20-20-20)", "Chime every 20 minutes as a reminder to look away", a.Config.EyeBreaks)
+	eyeBreakTicker := time.NewTicker(audio.EyeBreakInterval)
+	var eyeBreakTick <-chan time.Time
+	if a.Config.EyeBreaks {
+		eyeBreakTick = eyeBreakTicker.C
+	} else {
+		eyeBreakTicker.Stop()
+	}
+
+	// Rotate sounds crossfades to another sound at a fixed interval,
+	// picked from its submenu
+	mRotate := systray.AddMenuItemCheckbox("Rotate sounds", "Switch to another sound every "+audio.ShortDuration(a.Rotate.Interval), a.Rotate.Active())
+	mRotateEvery := systray.AddMenuItem("Rotate every", "How often Rotate sounds switches")
+	rotateClicked := make(chan time.Duration)
+	rotateItems := make(map[time.Duration]*systray.MenuItem)
+	for _, d := range append(audio.RotateIntervals, a.Rotate.Interval) {
+		if _, ok := rotateItems[d]; ok {
+			continue
+		}
+		item := mRotateEvery.AddSubMenuItemCheckbox(audio.ShortDuration(d), "Switch sounds every "+audio.ShortDuration(d), d == a.Rotate.Interval)
+		rotateItems[d] = item
+		go func(d time.Duration, m *systray.MenuItem) {
+			for {
+				<-m.ClickedCh
+				rotateClicked <- d
+			}
+		}(d, item)
+	}
+	updateRotateMenu := func() {
+		for d, item := range rotateItems {
+			if d == a.Rotate.Interval {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+		}
+		mRotate.SetTooltip("Switch to another sound every " + audio.ShortDuration(a.Rotate.Interval))
+		mRotateEvery.SetTitle("Rotate every " + audio.ShortDuration(a.Rotate.Interval))
+	}
+	updateRotateMenu()
+
+	// Sleep timer submenu, with the -sleep-custom length and then one
+	// typed in last
+	mSleep := systray.AddMenuItem("Sleep timer", "Fade out and stop after a while")
+	mSleepOff := mSleep.AddSubMenuItemCheckbox("Off", "Keep playing", true)
+	sleepClicked := make(chan time.Duration)
+	sleepItems := make(map[time.Duration]*systray.MenuItem)
+	for _, d := range append(audio.SleepDurations, a.SleepCustom) {
+		if _, ok := sleepItems[d]; ok || d <= 0 {
+			continue
+		}
+		item := mSleep.AddSubMenuItemCheckbox(audio.ShortDuration(d), "Stop playing after "+audio.ShortDuration(d), false)
+		sleepItems[d] = item
+		go func(d time.Duration, m *systray.MenuItem) {
+			for {
+				<-m.ClickedCh
+				sleepClicked <- d
+			}
+		}(d, item)
+	}
+	mSleepCustom := mSleep.AddSubMenuItemCheckbox("Custom...", "Stop playing after a time of your choice", false)
+	sleep := audio.NewSleepTimer()
+	updateSleepMenu := func(picked time.Duration) {
+		for d, item := range sleepItems {
+			if d == picked && sleep.Active() {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+		}
+		if _, listed := sleepItems[picked]; !listed && sleep.Active() {
+			mSleepCustom.Check()
+			mSleepCustom.SetTitle("Custom (" + audio.ShortDuration(picked) + ")...")
+		} else {
+			mSleepCustom.Uncheck()
+			mSleepCustom.SetTitle("Custom...")
+		}
+		if sleep.Active() {
+			mSleepOff.Uncheck()
+			mSleep.SetTitle("Sleep timer (until " + sleep.End.Format("15:04") + ")")
+		} else {
+			mSleepOff.Check()
+			mSleep.SetTitle("Sleep timer")
+		}
+	}
+
+	// Presets submenu; saving asks for a name, offering the sounds of the
+	// mix, and for a go-ahead before replacing a preset of the same name
+	mPresets := systray.AddMenuItem("Presets", "Save and recall mixes")
+	mPresetSave := mPresets.AddSubMenuItem("Save current mix...", "Save the sounds, their volumes and effects as a preset")
+	mPackImport := mPresets.AddSubMenuItem("Import sound pack...", "Add the sounds of a "+audio.PackExt+" file and save its mix as a preset")
+	mPackExport := mPresets.AddSubMenuItem("Export mix as sound pack...", "Save the sounds of the mix to a "+audio.PackExt+" file to share")
+	packImported := make(chan packImport)
+	presetNamed := make(chan audio.Preset)
+	presetClicked := make(chan string)
+	addPresetItem := func(name string) {
+		item := mPresets.AddSubMenuItem(name, "Play this mix")
+		go func() {
+			for {
+				<-item.ClickedCh
+				presetClicked <- name
+			}
+		}()
+	}
+	for _, p := range a.Config.Presets {
+		addPresetItem(p.Name)
+	}
+
+	// Output device submenu, only where a device can be picked
+	mOutput := systray.AddMenuItem("Output device", "Pick the device to play on")
+	mOutputDefault := mOutput.AddSubMenuItemCheckbox("System default", "Play on the system default device", a.Player.Device == "")
+	outputClicked := make(chan string)
+	outputItems := make(map[string]*systray.MenuItem)
+	addOutputItem := func(device string) {
+		item := mOutput.AddSubMenuItemCheckbox(device, "Play on "+device, device == a.Player.Device)
+		outputItems[device] = item
+		go func() {
+			for {
+				<-item.ClickedCh
+				outputClicked <- device
+			}
+		}()
+	}
+	go func() {
+		for {
+			<-mOutputDefault.ClickedCh
+			outputClicked <- ""
+		}
+	}()
+	addOutputDevices := func() {
+		for _, device := range audio.ListOutputDevices() {
+			if _, ok := outputItems[device]; !ok {
+				addOutputItem(device)
+			}
+		}
+		if len(outputItems) == 0 {
+			mOutput.Hide()
+		} else {
+			mOutput.Show()
+		}
+	}
+	addOutputDevices()
+	updateOutputMenu := func() {
+		for device, item := range outputItems {
+			if device == a.Player.Device {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+		}
+		if a.Player.Device == "" {
+			mOutputDefault.Check()
+		} else {
+			mOutputDefault.Uncheck()
+		}
+	}
+
+	catalog := newCatalogMenu(a.Config.CatalogURL)
+	freesound := newFreesoundMenu(a.Config.FreesoundToken)
+
+	// The control API token, to copy into scripts and dashboards
+	mAPIToken := systray.AddMenuItem("Control API token...", "Show the token the control API asks for")
+	if a.Remote.Token == "" {
+		mAPIToken.Hide()
+	}
+
+	mAutoplay := systray.AddMenuItemCheckbox("Play on start", "Start playing when the app is launched", a.Config.Autoplay)
+
+	mQuit := systray.AddMenuItem("Quit", "Quit the app")
+
+	// Every state change shows in the tooltip and the check marks
+	a.onChange = func(status audio.Status) {
+		if icon, ok := icons.icon(status); ok {
+			systray.SetIcon(icon)
+		}
+		systray.SetTooltip(trayTooltip(status))
+		mNowPlaying.SetTitle(status.NowPlaying)
+		if status.Muted {
+			mMute.Check()
+		} else {
+			mMute.Uncheck()
+		}
+		for p, item := range volumeItems {
+			if p == audio.VolumePercent(a.Player.Volume) {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+		}
+		for sound, menu := range soundMenus {
+			menu.update(a.Player, sound, a.Config.IsFavorite(sound))
+		}
+		custom := true
+		for eq, item := range eqItems {
+			if eq == a.Player.EQ {
+				item.Check()
+				custom = false
+			} else {
+				item.Uncheck()
+			}
+		}
+		if custom {
+			mEQCustom.Check()
+		} else {
+			mEQCustom.Uncheck()
+		}
+		for r, item := range reverbItems {
+			setChecked(item, r == a.Player.Reverb)
+		}
+		favorites.update(a.Player, a.Config.Favorites)
+		recent.update(a.Player, a.Config.Recent)
+	}
+	a.publish()
+
+	// Sounds picked from the tray, including Favorites and Recent
+	toggleSound := func(path string) {
+		a.Player.ToggleSound(path)
+		if a.Player.Channel(path) != nil {
+			a.Config.UseSound(path)
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case <-mPlay.ClickedCh:
+				if !a.Player.IsPlaying() && a.Output.CanPlay() {
+					a.Player.Play()
+				}
+			case <-mPause.ClickedCh:
+				a.Output.Held = false
+				a.Player.Pause()
+			case p := <-volumeClicked:
+				a.setVolumePercent(p)
+			case <-mMute.ClickedCh:
+				a.onHotkey(hotkeyMute)
+			case <-mVolumeUp.ClickedCh:
+				a.setVolumePercent(stepPercent(audio.VolumePercent(a.Player.Volume), 1))
+			case <-mVolumeDown.ClickedCh:
+				a.setVolumePercent(stepPercent(audio.VolumePercent(a.Player.Volume), -1))
+			case <-mVolumeSet.ClickedCh:
+				// The dialog blocks, so it waits off the event loop
+				current := strconv.Itoa(audio.VolumePercent(a.Player.Volume))
+				go func() {
+					text, ok := promptText("AmbiantGo", "Volume (0 to 100%)", current)
+					if !ok {
+						return
+					}
+					p, err := parsePercent(text)
+					if err != nil {
+						logger.Warn("Invalid volume", "err", err)
+						return
+					}
+					volumeClicked <- p
+				}()
+			case change := <-a.libraryChanged:
+				switch {
+				case change.Removed:
+					if a.Player.RemoveFromLibrary(change.Path) {
+						soundMenus[change.Path].parent.Hide()
+					}
+				case a.Player.AddToLibrary(change.Path):
+					// systray can't delete items, so a sound that comes
+					// back reuses its hidden entry
+					if menu, ok := soundMenus[change.Path]; ok {
+						menu.parent.Show()
+					} else {
+						addSoundItem(change.Path)
+					}
+				}
+			case now := <-a.minuteTick:
+				a.onMinute(now)
+			case change := <-a.deviceChanged:
+				a.onDeviceChange(change)
+				addOutputDevices()
+			case device := <-outputClicked:
+				a.Player.SetOutput(device)
+				updateOutputMenu()
+				a.Config.OutputDevice = device
+				a.Config.Save()
+			case action := <-a.hotkeyPressed:
+				a.onHotkey(action)
+			case action := <-a.mediaKeyPressed:
+				a.onHotkey(action)
+			case event := <-a.sessionChanged:
+				a.onSessionEvent(event)
+			case cmd := <-a.Remote.Commands:
+				cmd.Reply <- a.runRemote(cmd)
+			case reply := <-a.Remote.Status:
+				reply <- a.Player.Status()
+			case <-mQuit.ClickedCh:
+				systray.Quit()
+				return
+			case path := <-soundClicked:
+				toggleSound(path)
+			case i := <-favorites.clicked:
+				toggleSound(favorites.sounds[i])
+			case i := <-recent.clicked:
+				toggleSound(recent.sounds[i])
+			case path := <-favoriteClicked:
+				a.Config.ToggleFavorite(path)
+			case path := <-regionChanged:
+				a.Player.ReloadSound(path)
+			case v := <-soundVolumeClicked:
+				a.Player.SetChannelVolume(v.path, v.volume)
+			case e := <-soundEffectClicked:
+				fx := a.Player.SoundEffects(e.path)
+				e.apply(&fx)
+				a.Player.SetSoundEffects(e.path, fx)
+			case <-mRotate.ClickedCh:
+				if mRotate.Checked() {
+					mRotate.Uncheck()
+				} else {
+					mRotate.Check()
+				}
+				a.Rotate.Set(mRotate.Checked())
+				a.Config.Rotate = a.Rotate.Active()
+				a.Config.Save()
+			case d := <-rotateClicked:
+				a.Rotate.SetInterval(d)
+				updateRotateMenu()
+				a.Config.RotateInterval = d
+				a.Config.Save()
+			case d := <-sleepClicked:
+				sleep.Start(a.Player, d)
+				updateSleepMenu(d)
+			case <-mSleepCustom.ClickedCh:
+				// The dialog blocks, so it waits off the event loop
+				current := audio.ShortDuration(a.SleepCustom)
+				go func() {
+					text, ok := promptText("Sleep timer", "Stop playing after, e.g. 45m or 1h30m", current)
+					if !ok {
+						return
+					}
+					d, err := audio.ParseSleepDuration(text)
+					if err != nil {
+						logger.Warn("Invalid sleep timer length", "err", err)
+						return
+					}
+					sleepClicked <- d
+				}()
+			case <-mSleepOff.ClickedCh:
+				sleep.Cancel(a.Player)
+				updateSleepMenu(0)
+			case <-sleep.C():
+				if sleep.Fire(a.Player) {
+					updateSleepMenu(0)
+				}
+			case <-mPresetSave.ClickedCh:
+				if len(a.Player.Channels) == 0 {
+					break
+				}
+				p := a.Player.CurrentPreset()
+				taken := make(map[string]bool)
+				for _, preset := range a.Config.Presets {
+					taken[preset.Name] = true
+				}
+				// The dialogs block, so they wait off the event loop
+				go func() {
+					name, ok := promptText("Save preset", "Name of the preset", p.Name)
+					if !ok {
+						return
+					}
+					if taken[name] && !confirm("Save preset", "A preset named "+name+" exists already. Replace it?") {
+						return
+					}
+					p.Name = name
+					presetNamed <- p
+				}()
+			case p := <-presetNamed:
+				if a.Config.SavePreset(p) {
+					addPresetItem(p.Name)
+				}
+			case <-mPackImport.ClickedCh:
+				dir := a.Player.SoundsDir
+				go func() {
+					path, ok := pickFile("Import sound pack", audio.PackExt, false)
+					if !ok {
+						return
+					}
+					p, err := audio.ImportPack(path, dir)
+					packImported <- packImport{p, err}
+				}()
+			case r := <-packImported:
+				if r.err != nil {
+					logger.Error("Importing a sound pack failed", "err", r.err)
+					a.Notify("AmbiantGo", "The sound pack could not be imported.")
+					break
+				}
+				r.preset.Volume = a.Player.Volume
+				if a.Config.SavePreset(r.preset) {
+					addPresetItem(r.preset.Name)
+				}
+				a.Notify("AmbiantGo", r.preset.Name+" has been added to the Presets menu.")
+			case <-mPackExport.ClickedCh:
+				if len(a.Player.Channels) == 0 {
+					break
+				}
+				p := a.Player.CurrentPreset()
+				go func() {
+					path, ok := pickFile("Export sound pack", audio.PackExt, true)
+					if !ok {
+						return
+					}
+					if err := audio.ExportPack(path, p); err != nil {
+						logger.Error("Exporting a sound pack failed", "err", err)
+						a.Notify("AmbiantGo", "The sound pack could not be exported.")
+					}
+				}()
+			case name := <-presetClicked:
+				for _, p := range a.Config.Presets {
+					if p.Name == name {
+						a.Player.ApplyPreset(p)
+						a.Volumes.Remember(a.Player.Volume)
+					}
+				}
+			case <-catalog.load.ClickedCh:
+				catalog.fetch(a.Config.CatalogURL)
+			case r := <-catalog.loaded:
+				catalog.show(r, a.Player.SoundsDir, a.Notify)
+			case name := <-catalog.clicked:
+				catalog.download(name, a.Player.SoundsDir)
+			case p := <-catalog.progress:
+				catalog.update(p, a.Notify)
+			case <-freesound.search.ClickedCh:
+				freesound.ask(a.Config.FreesoundToken)
+			case r := <-freesound.found:
+				freesound.show(r, a.Notify)
+			case i := <-freesound.preview:
+				freesound.listen(i)
+			case data := <-freesound.previewed:
+				if err := a.Player.PlayPreview(data); err != nil {
+					logger.Error("Playing a preview failed", "err", err)
+				}
+			case i := <-freesound.add:
+				freesound.download(i, a.Player.SoundsDir)
+			case r := <-freesound.added:
+				freesound.done(r, a.Notify)
+			case eq := <-eqClicked:
+				a.Player.SetEQ(eq)
+			case r := <-reverbClicked:
+				a.Player.SetReverb(r)
+			case <-mEQCustom.ClickedCh:
+				current := a.Player.EQ.String()
+				go func() {
+					text, ok := promptText("Equalizer", "Low, mid and high gain in dB, e.g. 3 0 -4", current)
+					if !ok {
+						return
+					}
+					eq, err := audio.ParseEQ(text)
+					if err != nil {
+						logger.Warn("Invalid equalizer setting", "err", err)
+						return
+					}
+					eqClicked <- eq
+				}()
+			case <-mAPIToken.ClickedCh:
+				// Shown in a text box, where it can be selected and copied
+				go promptText("Control API token", "Send as \"Authorization: Bearer <token>\"", a.Remote.Token)
+			case <-mAutoplay.ClickedCh:
+				if mAutoplay.Checked() {
+					mAutoplay.Uncheck()
+				} else {
+					mAutoplay.Check()
+				}
+				a.Config.Autoplay = mAutoplay.Checked()
+				a.Config.Save()
+			case <-mEyeBreaks.ClickedCh:
+				if mEyeBreaks.Checked() {
+					mEyeBreaks.Uncheck()
+					eyeBreakTicker.Stop()
+					eyeBreakTick = nil
+				} else {
+					mEyeBreaks.Check()
+					eyeBreakTicker.Reset(audio.EyeBreakInterval)
+					eyeBreakTick = eyeBreakTicker.C
+				}
+				a.Config.EyeBreaks = mEyeBreaks.Checked()
+				a.Config.Save()
+			case <-eyeBreakTick:
+				a.Player.PlayChime()
+				a.Notify("AmbiantGo", audio.EyeBreakMessage)
+			case <-a.Rotate.C:
+				a.Rotate.Rotate(a.Player)
+			case <-a.Player.Changed():
+			case path := <-a.Player.Analysed():
+				a.Player.ApplyAnalysis(path)
+			case errs := <-a.Player.Unplayable():
+				for _, path := range a.Player.DropUnplayable(errs) {
+					soundMenus[path].parent.Hide()
+				}
+			}
+			a.publish()
+		}
+	}()
+}
+
+// soundMenu holds the tray items of one sound in the Sounds submenu
+type soundMenu struct {
+	parent *systray.MenuItem
+	toggle *systray.MenuItem
+	pin    *systray.MenuItem
+	levels []*systray.MenuItem // one per entry of channelVolumeLevels
+
+	muffle    []*systray.MenuItem // one per entry of LowPassLevels
+	intensity []*systray.MenuItem // one per entry of IntensityLevels
+	pan       []*systray.MenuItem // one per entry of PanLevels
+	width     []*systray.MenuItem // one per entry of WidthLevels
+	speed     []*systray.MenuItem // one per entry of SpeedLevels
+}
+
+// packImport is an imported sound pack's mix, or why it failed
+type packImport struct {
+	preset audio.Preset
+	err    error
+}
+
+// soundVolume is a volume picked for one sound from the tray
+type soundVolume struct {
+	path   string
+	volume float64
+}
+
+// soundEffect is an effect picked for one sound from the tray
+type soundEffect struct {
+	path  string
+	apply func(fx *audio.SoundEffects)
+}
+
+// update sets the check marks of a sound's items to match the player
+func (m *soundMenu) update(sp *audio.Player, path string, favorite bool) {
+	if sp.Channel(path) != nil {
+		m.parent.Check()
+		m.toggle.Check()
+	} else {
+		m.parent.Uncheck()
+		m.toggle.Uncheck()
+	}
+	if favorite {
+		m.pin.Check()
+	} else {
+		m.pin.Uncheck()
+	}
+
+	for i, level := range audio.ChannelVolumeLevels {
+		if level.Volume == sp.ChannelVolume(path) {
+			m.levels[i].Check()
+		} else {
+			m.levels[i].Uncheck()
+		}
+	}
+
+	fx := sp.SoundEffects(path)
+	for i, level := range audio.LowPassLevels {
+		setChecked(m.muffle[i], level.Cutoff == fx.LowPass)
+	}
+	for i, level := range audio.IntensityLevels {
+		setChecked(m.intensity[i], level.Intensity == fx.Intensity)
+	}
+	for i, level := range audio.PanLevels {
+		setChecked(m.pan[i], level.Pan == fx.Pan)
+	}
+	for i, level := range audio.WidthLevels {
+		setChecked(m.width[i], level.Width == fx.Width)
+	}
+	for i, level := range audio.SpeedLevels {
+		setChecked(m.speed[i], level.Speed == fx.Speed)
+	}
+}
+
+// editLoopRegion asks for a sound's loop markers and saves them, then
+// sends the sound on changed so it can be reloaded. It blocks on the
+// dialog, so it must run off the event loop.
+func editLoopRegion(path string, changed chan<- string) {
+	region, ok := promptText("Loop region", "Part of "+audio.SoundName(path)+" to loop, e.g. 0:08-59:30 (empty for the whole file)", audio.LoopRegion(path))
+	if !ok {
+		return
+	}
+	if err := audio.SetLoopRegion(path, region); err != nil {
+		logger.Error("Setting the loop region failed", "sound", path, "err", err)
+		return
+	}
+	changed <- path
+}
+
+// setChecked checks or unchecks a menu item
+func setChecked(item *systray.MenuItem, checked bool) {
+	if checked {
+		item.Check()
+	} else {
+		item.Uncheck()
+	}
+}
+
+// trayTooltip describes the state for the tray icon, e.g.
+// "Playing: Forest Rain — 60%"
+func trayTooltip(status audio.Status) string {
+	if len(status.Sounds) == 0 {
+		return "AmbiantGo"
+	}
+	if status.Muted {
+		return status.NowPlaying + " — muted"
+	}
+	return fmt.Sprintf("%s — %.0f%%", status.NowPlaying, status.Volume*100)
+}
+
+// loadIcon reads an ICO file built into the app and returns its byte
+// content
+func loadIcon(icons fs.FS, filename string) []byte {
+	// Read the entire ICO file
+	iconBytes, err := fs.ReadFile(icons, filename)
+	if err != nil {
+		logger.Error("Loading the tray icon failed", "err", err)
+		return nil
+	}
+	return iconBytes
+}
@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// volumeStepPercent is the step between the levels of the Volume menu
+const volumeStepPercent = 10
+
+// stepPercent moves a percentage to the next step up or down, snapping
+// to the steps of the Volume menu
+func stepPercent(percent, step int) int {
+	if step > 0 {
+		percent = (percent/volumeStepPercent + 1) * volumeStepPercent
+	} else {
+		percent = ((percent+volumeStepPercent-1)/volumeStepPercent - 1) * volumeStepPercent
+	}
+	return max(0, min(100, percent))
+}
+
+// parsePercent reads a volume typed in, e.g. "45" or "45%"
+func parsePercent(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%")))
+	if err != nil || p < 0 || p > 100 {
+		return 0, fmt.Errorf("invalid volume %q, expected 0 to 100", s)
+	}
+	return p, nil
+}
+
+// setVolumePercent sets the volume picked by the user as a percentage of
+// full volume, 0 being silent
+func (a *App) setVolumePercent(percent int) {
+	a.setVolume(audio.FractionVolume(float64(percent) / 100))
+}
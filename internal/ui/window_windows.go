@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"rogverse.fyi/ambiantgo/internal/winapi"
+)
+
+var (
+	procGetModuleHandle = winapi.Kernel32.NewProc("GetModuleHandleW")
+	procRegisterClassEx = winapi.User32.NewProc("RegisterClassExW")
+	procCreateWindowEx  = winapi.User32.NewProc("CreateWindowExW")
+	procDefWindowProc   = winapi.User32.NewProc("DefWindowProcW")
+	procDispatchMessage = winapi.User32.NewProc("DispatchMessageW")
+)
+
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   uintptr
+	icon       uintptr
+	cursor     uintptr
+	background uintptr
+	menuName   *uint16
+	className  *uint16
+	iconSm     uintptr
+}
+
+// hiddenWindow creates an invisible top-level window for APIs that need
+// one, handling its messages with wndProc
+func hiddenWindow(class string, wndProc uintptr) (uintptr, error) {
+	className, _ := syscall.UTF16PtrFromString(class)
+	instance, _, _ := procGetModuleHandle.Call(0)
+
+	wc := wndClassEx{
+		wndProc:   wndProc,
+		instance:  instance,
+		className: className,
+	}
+	wc.size = uint32(unsafe.Sizeof(wc))
+	procRegisterClassEx.Call(uintptr(unsafe.Pointer(&wc)))
+
+	title, _ := syscall.UTF16PtrFromString("AmbiantGo")
+	hwnd, _, err := procCreateWindowEx.Call(0, uintptr(unsafe.Pointer(className)), uintptr(unsafe.Pointer(title)),
+		0, 0, 0, 0, 0, 0, 0, instance, 0)
+	if hwnd == 0 {
+		return 0, fmt.Errorf("CreateWindowEx failed: %v", err)
+	}
+	return hwnd, nil
+}
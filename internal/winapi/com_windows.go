@@ -0,0 +1,46 @@
+// Package winapi holds the Windows DLLs and COM helpers shared by the
+// other packages.
+package winapi
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// The system DLLs shared by the Windows code
+var (
+	Kernel32 = syscall.NewLazyDLL("kernel32.dll")
+	User32   = syscall.NewLazyDLL("user32.dll")
+	Ole32    = syscall.NewLazyDLL("ole32.dll")
+
+	procCoInitializeEx = Ole32.NewProc("CoInitializeEx")
+	procCoUninitialize = Ole32.NewProc("CoUninitialize")
+)
+
+// iunknownRelease is the vtable slot of IUnknown::Release
+const iunknownRelease = 2
+
+// ComCall invokes method number slot on the COM object obj
+func ComCall(obj unsafe.Pointer, slot int, args ...uintptr) uintptr {
+	vtbl := *(**[64]uintptr)(obj)
+	hr, _, _ := syscall.SyscallN(vtbl[slot], append([]uintptr{uintptr(obj)}, args...)...)
+	return hr
+}
+
+// ComRelease releases a COM object; nil is ignored
+func ComRelease(obj unsafe.Pointer) {
+	if obj != nil {
+		ComCall(obj, iunknownRelease)
+	}
+}
+
+// ComInit initializes COM on the current thread, which must stay locked
+// until the returned cleanup function is called
+func ComInit() (func(), error) {
+	hr, _, _ := procCoInitializeEx.Call(0, 0)
+	if int32(hr) < 0 {
+		return nil, fmt.Errorf("CoInitializeEx failed: 0x%08x", uint32(hr))
+	}
+	return func() { procCoUninitialize.Call() }, nil
+}
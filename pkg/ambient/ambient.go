@@ -0,0 +1,141 @@
+// Package ambient plays looping ambient sound mixes, the engine behind the
+// AmbiantGo tray app, for use in other Go programs.
+//
+//	p := ambient.New("sounds")
+//	defer p.Close()
+//	p.Select("Rain")
+//	p.Add("Fireplace")
+//	p.SetVolume(0.5)
+//	p.Play()
+//
+// Sounds are named as in the tray menu: the file name without its
+// extension, or a built-in sound such as "White noise".
+package ambient
+
+import (
+	"fmt"
+	"sync"
+
+	"rogverse.fyi/ambiantgo/internal/audio"
+)
+
+// Status describes the player: whether it plays, the sounds in the mix,
+// the volume from 0 to 1 and the sounds in the library
+type Status = audio.Status
+
+// Backend is what a player plays through, shaped after beep's speaker
+// package; New uses the default output device
+type Backend = audio.Backend
+
+// FakeBackend plays nowhere: the mix only advances when its Pull method
+// is called, for testing code that uses a Player without a sound card
+type FakeBackend = audio.FakeBackend
+
+// Player plays a mix of sounds on the default output device. Its methods
+// are safe to call from any goroutine.
+type Player struct {
+	mu     sync.Mutex
+	player *audio.Player
+}
+
+// New creates a player for the audio files in soundsDir and the built-in
+// noise and tones. Nothing plays until Play is called.
+func New(soundsDir string) *Player {
+	return &Player{player: audio.NewPlayer(soundsDir, "")}
+}
+
+// NewWithBackend creates a player that plays through b, e.g. a
+// &FakeBackend{}
+func NewWithBackend(soundsDir string, b Backend) *Player {
+	return &Player{player: audio.NewPlayerWithBackend(soundsDir, b)}
+}
+
+// Sounds returns the names of the sounds that can be played
+func (p *Player) Sounds() []string {
+	return p.Status().Library
+}
+
+// Status returns the current state of the player
+func (p *Player) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.player.Status()
+}
+
+// Play starts playing the mix, fading in
+func (p *Player) Play() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.player.IsPlaying() {
+		return nil
+	}
+	return p.player.Play()
+}
+
+// Pause fades the mix out and stops it
+func (p *Player) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.player.Pause()
+}
+
+// Select replaces the mix with a single sound
+func (p *Player) Select(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	path, err := p.find(name)
+	if err != nil {
+		return err
+	}
+	p.player.SelectSound(path)
+	return nil
+}
+
+// Add mixes another sound in
+func (p *Player) Add(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	path, err := p.find(name)
+	if err != nil {
+		return err
+	}
+	return p.player.AddSound(path)
+}
+
+// Remove takes a sound out of the mix
+func (p *Player) Remove(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	path, err := p.find(name)
+	if err != nil {
+		return err
+	}
+	p.player.RemoveSound(path)
+	return nil
+}
+
+// SetVolume sets the volume of the mix from 0 (silent) to 1 (full)
+func (p *Player) SetVolume(level float64) error {
+	if level < 0 || level > 1 {
+		return fmt.Errorf("invalid volume %v, expected 0 to 1", level)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.player.SetVolume(audio.FractionVolume(level))
+	return nil
+}
+
+// Close stops playback and releases the output device and sound files
+func (p *Player) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.player.Close()
+}
+
+func (p *Player) find(name string) (string, error) {
+	path, ok := p.player.FindSound(name)
+	if !ok {
+		return "", fmt.Errorf("no sound named %q", name)
+	}
+	return path, nil
+}